@@ -0,0 +1,61 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// runDaemon collects and sends items every interval, and sends low level
+// discovery every discoInterval, until SIGTERM or SIGINT is received.
+// A cycle in progress is completed before returning.
+func runDaemon(interval time.Duration, discoInterval time.Duration) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGTERM, os.Interrupt)
+	defer signal.Stop(sigs)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var lastDiscovery time.Time
+	for {
+		sendDiscovery := lastDiscovery.IsZero() || time.Since(lastDiscovery) >= discoInterval
+		if runCycle(sendDiscovery) && sendDiscovery {
+			lastDiscovery = time.Now()
+		}
+
+		select {
+		case sig := <-sigs:
+			log.Info("Received ", sig, ", shutting down")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runCycle runs the enabled collectors into a clean cache, then sends discovery
+// if asked to and the items. It returns false if discovery could not be sent, so
+// that it is retried on the next cycle.
+func runCycle(sendDiscovery bool) bool {
+	resetCache()
+	if err := collect(collectors); err != nil {
+		log.Error("Collect failed : ", err)
+	}
+
+	discoverySent := true
+	if sendDiscovery {
+		if err := discovery(); err != nil {
+			log.Error("Sending discovery failed : ", err)
+			discoverySent = false
+		}
+	}
+	if err := updateItems(); err != nil {
+		log.Error("Sending items failed : ", err)
+	} else if err := commitEventLogPositions(); err != nil {
+		log.Error("Saving event log positions failed : ", err)
+	}
+	return discoverySent
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+const (
+	descDellHWEventLog         = "New entries of the hardware event log."
+	descDellHWEventLogCritical = "Number of critical entries in the hardware event log since it was last cleared."
+
+	eventLogStateFile = "eventlog.json"
+)
+
+// logEntry is an entry of a hardware event log. Items holding a []logEntry are
+// sent to Zabbix as one value per entry, for log type items.
+type logEntry struct {
+	Time     time.Time
+	Severity string
+	Text     string
+}
+
+func (e logEntry) String() string {
+	return e.Severity + " : " + e.Text
+}
+
+// eventLogPosition is the last seen position in an event log : the time of its
+// newest entry, and the entries seen during that second, since entries logged
+// later in the same second must still be reported.
+type eventLogPosition struct {
+	Last   int64    `json:"last"`
+	AtLast []string `json:"at_last,omitempty"`
+}
+
+// omreportEventLogs reports the entries of the ESM (SEL) and alert logs that
+// are newer than on the last run. The positions are kept in --state-dir, and
+// only saved by commitEventLogPositions once the entries are sent. On the
+// first run, only the position is recorded, to avoid sending the whole history.
+// A log that cannot be read is skipped and keeps its position.
+func omreportEventLogs(om omReporter) error {
+	positions := loadEventLogPositions()
+	errs := []error{}
+	for _, logName := range []string{"esmlog", "alertlog"} {
+		position, seen := positions[logName]
+		all := []logEntry{}
+		critical := 0
+		err := om.Report("LogEntry", func(r omRecord) {
+			entry := logEntry{Severity: r.status("Severity"), Text: r["Description"]}
+			if entry.Severity == "Critical" || entry.Severity == "Non-Recoverable" {
+				critical++
+			}
+			t, ok := parseLogTime(r["TimeStamp"])
+			if !ok {
+				return
+			}
+			entry.Time = t
+			all = append(all, entry)
+		}, "system", logName)
+		if err != nil {
+			// Keep the position, so the entries are read on the next run.
+			errs = append(errs, err)
+			continue
+		}
+
+		entries, next := newLogEntries(all, position)
+		if !seen {
+			entries = nil
+		}
+
+		ts := labels{"{#EVENTLOG}": logName}
+		add("dell.hardware.eventlog", logName, "critical", strconv.Itoa(critical), ts, descDellHWEventLogCritical)
+		if len(entries) > 0 {
+			sort.SliceStable(entries, func(i, j int) bool { return entries[i].Time.Before(entries[j].Time) })
+			metric := newItem("dell.hardware.eventlog", logName, "entries", "", ts, descDellHWEventLog)
+			metric.Value = entries
+			metric.Text = true
+			cache.set(*metric)
+		}
+		positions[logName] = next
+	}
+	cache.mu.Lock()
+	cache.eventLogPositions = positions
+	cache.mu.Unlock()
+	return firstError(errs...)
+}
+
+// commitEventLogPositions saves the event log positions reached by the last
+// collection. It must only be called once its items have been sent to Zabbix,
+// or the entries would never be seen.
+func commitEventLogPositions() error {
+	if cache.eventLogPositions == nil {
+		return nil
+	}
+	return saveEventLogPositions(cache.eventLogPositions)
+}
+
+// newLogEntries returns the entries of all that are newer than position, and
+// the position of the newest entry.
+func newLogEntries(all []logEntry, position eventLogPosition) ([]logEntry, eventLogPosition) {
+	seenAtLast := map[string]int{}
+	for _, e := range position.AtLast {
+		seenAtLast[e]++
+	}
+	entries := []logEntry{}
+	newest := position.Last
+	for _, entry := range all {
+		t := entry.Time.Unix()
+		if t == position.Last && seenAtLast[entry.String()] > 0 {
+			seenAtLast[entry.String()]--
+		} else if t >= position.Last {
+			entries = append(entries, entry)
+		}
+		if t > newest {
+			newest = t
+		}
+	}
+	next := eventLogPosition{Last: newest}
+	for _, entry := range all {
+		if entry.Time.Unix() == newest {
+			next.AtLast = append(next.AtLast, entry.String())
+		}
+	}
+	return entries, next
+}
+
+// parseLogTime reads an event log timestamp, either a Unix timestamp or the
+// date format of omreport text output.
+func parseLogTime(s string) (time.Time, bool) {
+	if sec, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(sec, 0), true
+	}
+	t, err := time.ParseInLocation(time.ANSIC, s, time.Local)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+func loadEventLogPositions() map[string]eventLogPosition {
+	positions := make(map[string]eventLogPosition)
+	b, err := ioutil.ReadFile(filepath.Join(stateDir, eventLogStateFile))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Error("Reading event log positions : ", err)
+		}
+		return positions
+	}
+	if err := json.Unmarshal(b, &positions); err != nil {
+		log.Error("Parsing event log positions : ", err)
+	}
+	return positions
+}
+
+func saveEventLogPositions(positions map[string]eventLogPosition) error {
+	b, err := json.Marshal(positions)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(stateDir, 0755); err != nil {
+		return err
+	}
+	// Write then rename, so that a crash never leaves a truncated state file.
+	path := filepath.Join(stateDir, eventLogStateFile)
+	if err := ioutil.WriteFile(path+".tmp", b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+// testLogReport is a mock omreport command returning the same log entries for
+// every log.
+type testLogReport struct {
+	entries []omRecord
+	err     error
+}
+
+func (o *testLogReport) Report(object string, f func(omRecord), args ...string) error {
+	if o.err != nil {
+		return o.err
+	}
+	for _, r := range o.entries {
+		f(r)
+	}
+	return nil
+}
+
+func (o *testLogReport) ReportObjects(fs map[string]func(omRecord), args ...string) error {
+	for _, f := range fs {
+		o.Report("", f, args...)
+	}
+	return nil
+}
+
+func TestOmreportEventLogs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dellhw_trapper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(d string) { stateDir = d }(stateDir)
+	stateDir = dir
+
+	to := &testLogReport{entries: []omRecord{
+		{"Severity": "4", "TimeStamp": "1484562281", "Description": "CPU 1 machine check error detected."},
+		{"Severity": "2", "TimeStamp": "1484562000", "Description": "Log cleared."},
+	}}
+	resetCache()
+	if err := omreportEventLogs(to); err != nil {
+		t.Fatal("Expected no error, got ", err)
+	}
+	if _, ok := cache.metrics["dell.hardware.eventlog[esmlog,entries]"]; ok {
+		t.Error("Expected no entries to be sent on the first run")
+	}
+	returnedValue := cache.metrics["dell.hardware.eventlog[esmlog,critical]"].Value
+	if returnedValue != "1" {
+		t.Error("Expected return value 1, got ", returnedValue)
+	}
+	if positions := loadEventLogPositions(); len(positions) != 0 {
+		t.Error("Expected no position saved before the items are sent, got ", positions)
+	}
+	if err := commitEventLogPositions(); err != nil {
+		t.Fatal("Expected no error, got ", err)
+	}
+
+	to.entries = append(to.entries, omRecord{"Severity": "3", "TimeStamp": "1484570000", "Description": "PS 1 input lost."})
+	resetCache()
+	if err := omreportEventLogs(to); err != nil {
+		t.Fatal("Expected no error, got ", err)
+	}
+	entries, ok := cache.metrics["dell.hardware.eventlog[alertlog,entries]"].Value.([]logEntry)
+	if !ok || len(entries) != 1 {
+		t.Fatal("Expected 1 new entry, got ", cache.metrics["dell.hardware.eventlog[alertlog,entries]"].Value)
+	}
+	if entries[0].String() != "Non-Critical : PS 1 input lost." {
+		t.Error("Expected Non-Critical : PS 1 input lost., got ", entries[0].String())
+	}
+	commitEventLogPositions()
+
+	// An entry logged in the same second as the last seen one is new.
+	to.entries = append(to.entries, omRecord{"Severity": "2", "TimeStamp": "1484570000", "Description": "PS 1 input restored."})
+	resetCache()
+	if err := omreportEventLogs(to); err != nil {
+		t.Fatal("Expected no error, got ", err)
+	}
+	entries, ok = cache.metrics["dell.hardware.eventlog[alertlog,entries]"].Value.([]logEntry)
+	if !ok || len(entries) != 1 || entries[0].Text != "PS 1 input restored." {
+		t.Fatal("Expected the entry of the same second, got ", cache.metrics["dell.hardware.eventlog[alertlog,entries]"].Value)
+	}
+	commitEventLogPositions()
+	resetCache()
+	omreportEventLogs(to)
+	if _, ok := cache.metrics["dell.hardware.eventlog[alertlog,entries]"]; ok {
+		t.Error("Expected no new entries, got ", cache.metrics["dell.hardware.eventlog[alertlog,entries]"].Value)
+	}
+
+	// A log that cannot be read gets no items and keeps its position.
+	before := loadEventLogPositions()
+	resetCache()
+	if err := omreportEventLogs(&testLogReport{err: errors.New("omreport not found")}); err == nil {
+		t.Error("Expected an error when the logs cannot be read")
+	}
+	if _, ok := cache.metrics["dell.hardware.eventlog[esmlog,critical]"]; ok {
+		t.Error("Expected no critical item when the log cannot be read")
+	}
+	commitEventLogPositions()
+	if after := loadEventLogPositions(); !reflect.DeepEqual(before, after) {
+		t.Error("Expected positions ", before, ", got ", after)
+	}
+	resetCache()
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// collectorInfo is a collector as printed by list-collectors.
+type collectorInfo struct {
+	Name string `json:"name"`
+	collector
+	Default bool `json:"default"`
+}
+
+// listCollectors prints every registered collector to w, sorted by name, as
+// text or as a JSON array.
+func listCollectors(w io.Writer, collectors map[string]collector, asJSON bool) error {
+	defaults := map[string]bool{}
+	for _, name := range strings.Split(defaultCollectors, ",") {
+		defaults[name] = true
+	}
+	infos := []collectorInfo{}
+	for name, c := range collectors {
+		infos = append(infos, collectorInfo{Name: name, collector: c, Default: defaults[name]})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+
+	if asJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(infos)
+	}
+	for _, info := range infos {
+		name := info.Name
+		if info.Default {
+			name += " (default)"
+		}
+		args := []string{}
+		for _, a := range info.Args {
+			args = append(args, "omreport "+a)
+		}
+		fmt.Fprintf(w, "%s\n", name)
+		fmt.Fprintf(w, "  %s\n", info.Description)
+		fmt.Fprintf(w, "  runs  : %s\n", strings.Join(args, ", "))
+		fmt.Fprintf(w, "  items : %s\n\n", strings.Join(info.Prefixes, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestListCollectors(t *testing.T) {
+	b := &bytes.Buffer{}
+	if err := listCollectors(b, collectors, true); err != nil {
+		t.Fatal("Expected no error, got ", err)
+	}
+	infos := []struct {
+		Name     string
+		Args     []string `json:"omreport_args"`
+		Prefixes []string `json:"item_prefixes"`
+		Default  bool
+	}{}
+	if err := json.Unmarshal(b.Bytes(), &infos); err != nil {
+		t.Fatal("Expected JSON output, got ", err)
+	}
+	if len(infos) != len(collectors) {
+		t.Error("Expected ", len(collectors), " collectors, got ", len(infos))
+	}
+	for _, info := range infos {
+		if len(info.Prefixes) == 0 || collectors[info.Name].Description == "" {
+			t.Error("Expected a description and item prefixes for collector ", info.Name)
+		}
+		if info.Name == "ps_amps_sysboard_pwr" && (!info.Default || info.Args[0] != "chassis pwrmonitoring") {
+			t.Error("Expected ps_amps_sysboard_pwr to be a default collector running chassis pwrmonitoring, got ", info)
+		}
+		if info.Name == "nics" && info.Default {
+			t.Error("Expected nics not to be a default collector")
+		}
+	}
+}
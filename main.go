@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"os"
+	"time"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -13,6 +14,16 @@ var (
 	RootCmd = &cobra.Command{
 		Use:   "dellhw_trapper",
 		Short: "Zabbix exporter for Dell Hardware components",
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			setLogLevel()
+			if _, err := resolveCollectors(enabledCollectors, collectors); err != nil {
+				return err
+			}
+			if err := parseCollectorTimeouts(collectorTimeoutFlag, collectors); err != nil {
+				return err
+			}
+			return parseSeverityMap(severityMap)
+		},
 		Run: func(cmd *cobra.Command, args []string) {
 			runMainCommand()
 		},
@@ -29,23 +40,59 @@ var (
 	zabbixServerPort    string
 	zabbixDiscovery     bool
 	zabbixUpdateItems   bool
+	zabbixHostInventory bool
+	stateDir            string
+	severityMap         string
+	statusText          bool
+
+	listenAddress  string
+	metricsPath    string
+	scrapeInterval time.Duration
+
+	collectInterval   time.Duration
+	discoveryInterval time.Duration
+
+	collectParallelism   int
+	collectTimeout       time.Duration
+	collectorTimeoutFlag string
 
-	cache          = newMetricStorage()
-	metricCounts   = make(map[string]int)
-	metricStatuses = make(map[string]int)
+	listJSON bool
+
+	cache             = newMetricStorage()
+	metricCounts      = make(map[string]int)
+	metricStatuses    = make(map[string]int)
+	metricMaxStatuses = make(map[string]int)
 )
 
 func init() {
-	RootCmd.Flags().StringVarP(&logLevel, "loglevel", "L", "info", "Set log level")
-	RootCmd.Flags().StringVarP(&enabledCollectors, "collect", "c", "chassis,fans,memory,processors,ps,ps_amps_sysboard_pwr,storage_battery,storage_enclosure,storage_controller,storage_vdisk,system,temps,volts", "Comma-separated list of collectors to use.")
-	RootCmd.Flags().StringVarP(&zabbixFromHost, "zabbix-from", "f", getFQDN(), "Send to Zabbix from this host name. You can also set HOSTNAME and DOMAINNAME environment variables.")
-	RootCmd.Flags().StringVarP(&zabbixServerAddress, "zabbix-server", "z", "localhost", "Zabbix server hostname or address")
-	RootCmd.Flags().StringVarP(&zabbixServerPort, "zabbix-port", "p", "10051", "Zabbix server port")
-	RootCmd.Flags().StringVarP(&discoveryNameSpace, "namespace", "n", "", "Discovery key")
+	RootCmd.PersistentFlags().StringVarP(&logLevel, "loglevel", "L", "info", "Set log level")
+	RootCmd.PersistentFlags().StringVarP(&enabledCollectors, "collect", "c", defaultCollectors, "Comma-separated list of collectors to use. Accepts all, globs such as storage_* and exclusions such as -storage_vdisk")
+	RootCmd.PersistentFlags().StringVarP(&zabbixFromHost, "zabbix-from", "f", getFQDN(), "Send to Zabbix from this host name. You can also set HOSTNAME and DOMAINNAME environment variables.")
+	RootCmd.PersistentFlags().StringVarP(&zabbixServerAddress, "zabbix-server", "z", "localhost", "Zabbix server hostname or address")
+	RootCmd.PersistentFlags().StringVarP(&zabbixServerPort, "zabbix-port", "p", "10051", "Zabbix server port")
+	RootCmd.PersistentFlags().StringVarP(&discoveryNameSpace, "namespace", "n", "", "Discovery key")
+	RootCmd.PersistentFlags().BoolVar(&zabbixHostInventory, "host-inventory", false, "Also send system identification under Zabbix host inventory field names (summary collector)")
+	RootCmd.PersistentFlags().StringVar(&stateDir, "state-dir", "/var/lib/dellhw_trapper", "Directory where the last seen event log positions are kept (eventlog collector)")
+	RootCmd.PersistentFlags().StringVar(&severityMap, "severity-map", "", "Comma-separated status=code pairs overriding the default status codes (Ok=0,Non-Critical=1,Critical=2,Non-Recoverable=3,Unknown=-1,Not Applicable=-2)")
+	RootCmd.PersistentFlags().BoolVar(&statusText, "status-text", false, "Also send the status reported by omreport as text, in status_text items")
+	RootCmd.PersistentFlags().IntVar(&collectParallelism, "parallel", 4, "Number of omreport commands to run at the same time")
+	RootCmd.PersistentFlags().DurationVar(&collectTimeout, "collect-timeout", 50*time.Second, "Interrupt omreport calls still running after this time, so a run fits in the Zabbix trapper interval. No limit if 0")
+	RootCmd.PersistentFlags().StringVar(&collectorTimeoutFlag, "collector-timeout", "30s", "Interrupt the omreport calls of a collector after they ran this long, not counting the wait for --parallel, and kill them 5s later. Takes name=duration overrides, e.g. 30s,storage_controller=1m")
 	RootCmd.Flags().BoolVar(&zabbixDiscovery, "discovery", false, "Perform Zabbix low level discovery on hardware elements")
 	RootCmd.Flags().BoolVar(&zabbixUpdateItems, "update-items", false, "Get & send items to Zabbix. This is the default behaviour")
 	RootCmd.AddCommand(versionCmd)
 
+	serveCmd.Flags().StringVarP(&listenAddress, "listen-address", "l", ":9137", "Address to listen on for Prometheus scrapes")
+	serveCmd.Flags().StringVar(&metricsPath, "metrics-path", "/metrics", "Path under which to expose metrics")
+	serveCmd.Flags().DurationVar(&scrapeInterval, "scrape-interval", 0, "Run the collectors in the background at this interval. If 0, each scrape runs a full collection and waits for it")
+	RootCmd.AddCommand(serveCmd)
+
+	daemonCmd.Flags().DurationVar(&collectInterval, "interval", time.Minute, "Collect and send items at this interval")
+	daemonCmd.Flags().DurationVar(&discoveryInterval, "discovery-interval", time.Hour, "Send low level discovery at this interval")
+	RootCmd.AddCommand(daemonCmd)
+
+	listCollectorsCmd.Flags().BoolVar(&listJSON, "json", false, "Print the collectors as JSON")
+	RootCmd.AddCommand(listCollectorsCmd)
 }
 
 var versionCmd = &cobra.Command{
@@ -60,8 +107,37 @@ var versionCmd = &cobra.Command{
 	},
 }
 
-func runMainCommand() {
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Expose hardware metrics to Prometheus over HTTP",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := serve(listenAddress, metricsPath, scrapeInterval); err != nil {
+			log.Error(err)
+			os.Exit(1)
+		}
+	},
+}
+
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Keep running, collecting and sending items to Zabbix at regular intervals",
+	Run: func(cmd *cobra.Command, args []string) {
+		runDaemon(collectInterval, discoveryInterval)
+	},
+}
+
+var listCollectorsCmd = &cobra.Command{
+	Use:   "list-collectors",
+	Short: "List the collectors, the omreport commands they run and the items they send",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := listCollectors(os.Stdout, collectors, listJSON); err != nil {
+			log.Error(err)
+			os.Exit(1)
+		}
+	},
+}
 
+func setLogLevel() {
 	if logLevel == "info" {
 		log.SetLevel(log.InfoLevel)
 	}
@@ -71,18 +147,33 @@ func runMainCommand() {
 	if logLevel == "error" {
 		log.SetLevel(log.ErrorLevel)
 	}
+}
+
+func runMainCommand() {
 
+	// Failed collectors are reported by their own items, so what was
+	// collected is sent anyway.
 	err := collect(collectors)
 	if err != nil {
-		log.Debug("Collect failed")
-		os.Exit(1)
+		log.Error("Collect failed : ", err)
 	}
 
 	if zabbixDiscovery {
-		discovery()
-	} else {
-		updateItems()
+		err = discovery()
+	} else if err = updateItems(); err == nil {
+		if err := commitEventLogPositions(); err != nil {
+			log.Error("Saving event log positions failed : ", err)
+		}
+	}
+	if err == errDiscoveryMarshal {
+		fmt.Println("2")
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Println("4")
+		os.Exit(4)
 	}
+	fmt.Println("0")
 }
 
 func main() {
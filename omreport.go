@@ -1,9 +1,15 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"path"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	log "github.com/Sirupsen/logrus"
 )
@@ -13,64 +19,424 @@ const (
 	descDellHWSystem         = "Overall status of system components."
 	descDellHWStorageEnc     = "Overall status of storage enclosures."
 	descDellHWVDisk          = "Overall status of virtual disks."
+	descDellHWVDiskState     = "State of virtual disks (Ready, Degraded, Resynching...)."
+	descDellHWVDiskLayout    = "RAID layout of virtual disks."
+	descDellHWVDiskSize      = "Size of virtual disks, in bytes."
+	descDellHWVDiskRead      = "Read cache policy of virtual disks."
+	descDellHWVDiskWrite     = "Write cache policy of virtual disks. Write Through on a Write Back disk usually means a battery problem."
+	descDellHWVDiskCache     = "Physical disk cache policy of virtual disks."
+	descDellHWVDiskProgress  = "Progress percentage of the running reconstruction, consistency check or initialization on virtual disks."
 	descDellHWPS             = "Overall status of power supplies."
 	descDellHWCurrent        = "Amps used per power supply."
 	descDellHWPower          = "System board power usage."
 	descDellHWPowerThreshold = "The warning and failure levels set on the device for system board power usage."
+	descDellHWEnergy         = "Cumulative energy consumption, in kWh."
+	descDellHWEnergyStart    = "Start of the cumulative energy consumption measurement, as a Unix timestamp."
+	descDellHWPeakPower      = "System peak power, in Watts."
+	descDellHWPeakPowerTime  = "Time of the system peak power, as a Unix timestamp."
+	descDellHWPeakAmps       = "System peak amperage, in Amps."
+	descDellHWPeakAmpsTime   = "Time of the system peak amperage, as a Unix timestamp."
+	descDellHWAvgPower       = "Average system power over the last minute, hour, day or week, in Watts."
 	descDellHWStorageBattery = "Status of storage controller backup batteries."
+	descDellHWBatteryState   = "State of storage controller backup batteries (Ready, Charging, Learning, Degraded, Failed)."
+	descDellHWBatteryLearn   = "Whether storage controller backup batteries are in a learn cycle."
+	descDellHWBatteryCap     = "Predicted capacity status of storage controller backup batteries."
+	descDellHWBatteryMode    = "Learn mode of storage controller backup batteries."
+	descDellHWBatteryNext    = "Time until the next learn cycle of storage controller backup batteries, in hours."
+	descDellHWBatteryDelay   = "Maximum learn cycle delay of storage controller backup batteries, in hours."
 	descDellHWStorageCtl     = "Overall status of storage controllers."
+	descDellHWCtlFirmware    = "Firmware version of storage controllers."
+	descDellHWCtlDriver      = "Driver version of storage controllers."
+	descDellHWCtlCache       = "Cache memory size of storage controllers, in MB."
+	descDellHWCtlPatrolState = "Patrol read state of storage controllers."
+	descDellHWCtlPatrolMode  = "Patrol read mode of storage controllers."
+	descDellHWCtlRebuildRate = "Rebuild rate of storage controllers, in percent."
+	descDellHWCtlMinFirmware = "Minimum firmware version of storage controllers required by Dell."
+	descDellHWCtlMinDriver   = "Minimum driver version of storage controllers required by Dell."
+	descDellHWCtlOutdated    = "Whether storage controllers run a firmware or driver older than the minimum required by Dell."
 	descDellHWPDisk          = "Overall status of physical disks."
+	descDellHWPDiskState     = "State of physical disks (Online, Ready, Rebuilding, Failed...)."
+	descDellHWPDiskPredicted = "Whether a failure is predicted by SMART on physical disks."
+	descDellHWPDiskProgress  = "Progress percentage of the running operation on physical disks."
+	descDellHWPDiskProtocol  = "Bus protocol of physical disks."
+	descDellHWPDiskMedia     = "Media type of physical disks."
+	descDellHWPDiskCapacity  = "Capacity of physical disks, in bytes."
+	descDellHWPDiskUsed      = "Space of physical disks used by RAID, in bytes."
+	descDellHWPDiskHotSpare  = "Hot spare status of physical disks."
+	descDellHWPDiskEndurance = "Remaining rated write endurance of SSD physical disks, in percent."
 	descDellHWCPU            = "Overall status of CPUs."
+	descDellHWCPUBrand       = "Brand of CPUs."
+	descDellHWCPUSpeed       = "Current speed of CPUs, in MHz."
+	descDellHWCPUMaxSpeed    = "Maximum speed of CPUs, in MHz."
+	descDellHWCPUCores       = "Number of cores of CPUs."
+	descDellHWCPUState       = "State of CPUs (Present, Disabled...)."
+	descDellHWCPUMismatch    = "Whether CPUs have fewer cores or a lower speed than another CPU of the system."
 	descDellHWFan            = "Overall status of system fans."
 	descDellHWFanSpeed       = "System fan speed."
 	descDellHWMemory         = "System RAM DIMM status."
+	descDellHWMemorySize     = "Size of RAM DIMMs, in MB."
+	descDellHWMemoryType     = "Type of RAM DIMMs (DDR3, DDR4...)."
+	descDellHWMemorySpeed    = "Speed of RAM DIMMs, in MHz."
+	descDellHWMemoryTotal    = "Total installed memory, in MB."
+	descDellHWMemoryOS       = "Total memory available to the OS, in MB."
+	descDellHWMemoryMissing  = "Installed memory not available to the OS, in MB. A jump usually means DIMMs mapped out by the BIOS."
+	descDellHWMemoryRedMode  = "Memory redundancy configuration (mirroring, sparing...)."
+	descDellHWMemoryRedState = "Memory redundancy status."
 	descDellHWTemp           = "Overall status of system temperature readings."
 	descDellHWTempReadings   = "System temperature readings."
 	descDellHWVolt           = "Overall status of power supply volt readings."
 	descDellHWVoltReadings   = "Volts used per power supply."
+	descDellHWThreshold      = "The warning and failure levels set on the device for this probe, in the unit of its reading."
+	descDellHWStatusText     = "Status reported by omreport, followed by the component state if any."
+	descDellHWNic            = "Overall status of network interfaces and teams."
+	descDellHWNicLink        = "Whether network interfaces and teams are connected."
+	descDellHWNicSpeed       = "Link speed of network interfaces and teams, in Mbps."
+	descDellHWBiosVersion    = "BIOS version."
+	descDellHWBiosDate       = "BIOS release date."
+	descDellHWBmcVersion     = "iDRAC / BMC firmware version."
+	descDellHWLcVersion      = "Lifecycle controller version."
+	descDellHWFirmware       = "Firmware or software version of system components."
+	descDellHWModel          = "Chassis model."
+	descDellHWServiceTag     = "Service tag."
+	descDellHWExpressCode    = "Express service code."
+	descDellHWAssetTag       = "Asset tag."
+	descDellHWRevision       = "System revision."
+	descDellHWOmsaVersion    = "Dell OpenManage Server Administrator version."
+	descDellHWOsName         = "Operating system name."
+	descDellHWOsVersion      = "Operating system version."
+	descDellHWHostInventory  = "Value for the Zabbix host inventory field of the same name."
+	descDellHWCollector      = "Whether the collector ran without error."
+	descDellHWCollectorTime  = "Run time of the collector, in milliseconds."
 )
 
 var (
 	collectors = map[string]collector{
-		"dummy":      collector{F: dummyReport},
-		"chassis":    collector{F: omreportChassis},
-		"fans":       collector{F: omreportFans},
-		"memory":     collector{F: omreportMemory},
-		"processors": collector{F: omreportProcessors},
-		"ps":         collector{F: omreportPs},
-		"ps_amps_sysboard_pwr": collector{F: omreportPsAmpsSysboardPwr},
-		"storage_battery":      collector{F: omreportStorageBattery},
-		"storage_controller":   collector{F: omreportStorageController},
-		"storage_enclosure":    collector{F: omreportStorageEnclosure},
-		"storage_vdisk":        collector{F: omreportStorageVdisk},
-		"system":               collector{F: omreportSystem},
-		"temps":                collector{F: omreportTemps},
-		"volts":                collector{F: omreportVolts},
+		"dummy": collector{
+			F:           dummyReport,
+			Description: "Test collector sending a fixed dummy item.",
+			Args:        []string{},
+			Prefixes:    []string{"dummy"},
+		},
+		"chassis": collector{
+			F:           omreportChassis,
+			Description: "Overall status of each chassis component (fans, memory, power supplies...).",
+			Args:        []string{"chassis"},
+			Prefixes:    []string{"dell.hardware.chassis"},
+		},
+		"eventlog": collector{
+			F:           omreportEventLogs,
+			Description: "New entries of the ESM and alert logs since the last run, and their number of critical entries.",
+			Args:        []string{"system esmlog", "system alertlog"},
+			Prefixes:    []string{"dell.hardware.eventlog"},
+		},
+		"fans": collector{
+			F:           omreportFans,
+			Description: "Status, speed and thresholds of system fans.",
+			Args:        []string{"chassis fans"},
+			Prefixes:    []string{"dell.hardware.fan"},
+		},
+		"firmware": collector{
+			F:           omreportFirmware,
+			Description: "BIOS, iDRAC and lifecycle controller versions, and the firmware version of every component.",
+			Args:        []string{"chassis bios", "system version"},
+			Prefixes:    []string{"dell.hardware.inventory", "dell.hardware.firmware"},
+		},
+		"memory": collector{
+			F:           omreportMemory,
+			Description: "Status, size, type and speed of DIMMs, installed versus OS visible memory, and memory redundancy.",
+			Args:        []string{"chassis memory"},
+			Prefixes:    []string{"dell.hardware.memory"},
+		},
+		"nics": collector{
+			F:           omreportNics,
+			Description: "Status, link and speed of network interfaces and teams.",
+			Args:        []string{"chassis nics"},
+			Prefixes:    []string{"dell.hardware.nic"},
+		},
+		"processors": collector{
+			F:           omreportProcessors,
+			Description: "Status, brand, speed and cores of CPUs, and mismatches between them.",
+			Args:        []string{"chassis processors"},
+			Prefixes:    []string{"dell.hardware.processors"},
+		},
+		"ps": collector{
+			F:           omreportPs,
+			Description: "Status and input / output wattage of power supplies.",
+			Args:        []string{"chassis pwrsupplies"},
+			Prefixes:    []string{"dell.hardware.power"},
+		},
+		"ps_amps_sysboard_pwr": collector{
+			F:           omreportPsAmpsSysboardPwr,
+			Description: "Amps used per power supply, system board power usage and its thresholds, and the energy, peak and average power history.",
+			Args:        []string{"chassis pwrmonitoring"},
+			Prefixes:    []string{"dell.hardware.chassis.current", "dell.hardware.chassis.power", "dell.hardware.chassis.power.warn", "dell.hardware.chassis.power.fail"},
+		},
+		"storage_battery": collector{
+			F:           omreportStorageBattery,
+			Description: "Status, state and learn cycle of storage controller backup batteries.",
+			Args:        []string{"storage battery"},
+			Prefixes:    []string{"dell.hardware.storage.battery"},
+		},
+		"storage_controller": collector{
+			F:           omreportStorageController,
+			Description: "Status, versions and settings of storage controllers, and status, state and health of their physical disks.",
+			Args:        []string{"storage controller", "storage pdisk controller=<id>"},
+			Prefixes:    []string{"dell.hardware.raid.controller", "dell.hardware.raid.physicaldrive"},
+		},
+		"storage_enclosure": collector{
+			F:           omreportStorageEnclosure,
+			Description: "Overall status of storage enclosures.",
+			Args:        []string{"storage enclosure"},
+			Prefixes:    []string{"dell.hardware.storage.enclosure"},
+		},
+		"storage_vdisk": collector{
+			F:           omreportStorageVdisk,
+			Description: "Status, state, layout, size, cache policies and progress of virtual disks.",
+			Args:        []string{"storage vdisk"},
+			Prefixes:    []string{"dell.hardware.raid.logicaldrive"},
+		},
+		"summary": collector{
+			F:           omreportSummary,
+			Description: "System identification : model, service tag, asset tag, OMSA and OS versions.",
+			Args:        []string{"chassis info", "system summary"},
+			Prefixes:    []string{"dell.hardware.inventory", "dell.hardware.host_inventory"},
+		},
+		"system": collector{
+			F:           omreportSystem,
+			Description: "Overall status of each system component (main system chassis, storage...).",
+			Args:        []string{"system"},
+			Prefixes:    []string{"dell.hardware.system"},
+		},
+		"temps": collector{
+			F:           omreportTemps,
+			Description: "Status, reading and thresholds of temperature probes.",
+			Args:        []string{"chassis temps"},
+			Prefixes:    []string{"dell.hardware.chassis.temps"},
+		},
+		"volts": collector{
+			F:           omreportVolts,
+			Description: "Status, reading and thresholds of voltage probes.",
+			Args:        []string{"chassis volts"},
+			Prefixes:    []string{"dell.hardware.chassis.volts"},
+		},
 	}
 )
 
+// collector is a function adding items from omreport output. Description, Args
+// and Prefixes document what it does for list-collectors : the omreport
+// arguments it runs and the key prefixes of the items it adds.
 type collector struct {
-	F func(omReporter) error
+	F           func(omReporter) error `json:"-"`
+	Description string                 `json:"description"`
+	Args        []string               `json:"omreport_args"`
+	Prefixes    []string               `json:"item_prefixes"`
+}
+
+// defaultCollectors is the default value of --collect.
+const defaultCollectors = "chassis,fans,memory,processors,ps,ps_amps_sysboard_pwr,storage_battery,storage_enclosure,storage_controller,storage_vdisk,system,temps,volts"
+
+// resolveCollectors returns the names of the collectors selected by spec, a
+// comma-separated list of collector names, "all" for every collector, and glob
+// patterns such as "storage_*". The dummy test collector is only selected by
+// its name. A name or pattern prefixed with "-" is removed from the selection;
+// a spec made of exclusions only applies them to the default collectors.
+func resolveCollectors(spec string, collectors map[string]collector) ([]string, error) {
+	valid := collectorNames(collectors)
+	validList := strings.Join(valid, ", ")
+
+	terms := []string{}
+	onlyExclusions := true
+	for _, term := range strings.Split(spec, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+		terms = append(terms, term)
+		if !strings.HasPrefix(term, "-") {
+			onlyExclusions = false
+		}
+	}
+	if len(terms) > 0 && onlyExclusions {
+		terms = append(strings.Split(defaultCollectors, ","), terms...)
+	}
+
+	selected := []string{}
+	for _, term := range terms {
+		exclude := strings.HasPrefix(term, "-")
+		pattern := strings.TrimPrefix(term, "-")
+		matches := []string{}
+		for _, name := range valid {
+			match := name == pattern
+			if pattern == "all" {
+				match = name != "dummy"
+			} else if strings.ContainsAny(pattern, "*?[") {
+				var err error
+				if match, err = path.Match(pattern, name); err != nil {
+					return nil, fmt.Errorf("invalid collector pattern %q : %v", pattern, err)
+				}
+				match = match && name != "dummy"
+			}
+			if match {
+				matches = append(matches, name)
+			}
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("unknown collector %q, valid collectors are : %s", pattern, validList)
+		}
+		for _, name := range matches {
+			selected = removeString(selected, name)
+			if !exclude {
+				selected = append(selected, name)
+			}
+		}
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("no collector selected by %q, valid collectors are : %s", spec, validList)
+	}
+	return selected, nil
+}
+
+// collectorNames returns the sorted names of collectors.
+func collectorNames(collectors map[string]collector) []string {
+	names := make([]string, 0, len(collectors))
+	for name := range collectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// removeString returns ss without s.
+func removeString(ss []string, s string) []string {
+	kept := ss[:0]
+	for _, v := range ss {
+		if v != s {
+			kept = append(kept, v)
+		}
+	}
+	return kept
 }
 
 type labels map[string]string
-type omReport struct{}
 
+// omReport runs omreport for a collector. Each call takes one of slots while
+// it runs, if set, which bounds the number of omreport commands running at a
+// time across collectors. Waiting for a slot is only bounded by ctx, the
+// collection run; once running, calls are interrupted when their time added
+// to elapsed exceeds timeout.
+type omReport struct {
+	ctx     context.Context
+	timeout time.Duration
+	slots   chan struct{}
+
+	mu      sync.Mutex
+	elapsed time.Duration
+}
+
+// collect runs the enabled collectors, with at most --parallel omreport
+// commands running at a time. A failing collector does not stop the others :
+// its error is logged, and the success and duration_ms items of each collector
+// tell whether monitoring itself works. The returned error lists the
+// collectors that failed.
 func collect(collectors map[string]collector) error {
-	for _, name := range strings.Split(enabledCollectors, ",") {
-		collector := collectors[name]
-		log.Debug("Running collector ", name)
-		om := newOmReport()
-		err := collector.F(om)
-		if err != nil {
-			log.Error("Collector", name, "failed to run")
-			return err
+	names, err := resolveCollectors(enabledCollectors, collectors)
+	if err != nil {
+		return err
+	}
+	// omreport calls still running at the deadline are interrupted, so that a
+	// run fits in the Zabbix trapper interval.
+	ctx := context.Background()
+	if collectTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, collectTimeout)
+		defer cancel()
+	}
+	parallel := collectParallelism
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	type result struct {
+		err      error
+		duration time.Duration
+	}
+	results := make([]result, len(names))
+	slots := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, name string, collector collector) {
+			defer wg.Done()
+			log.Debug("Running collector ", name)
+			om := newOmReport(ctx, collectorTimeout(name), slots)
+			err := collector.F(om)
+			results[i] = result{err: err, duration: om.runTime()}
+		}(i, name, collectors[name])
+	}
+	wg.Wait()
+
+	failed := []string{}
+	for i, name := range names {
+		success := "1"
+		if err := results[i].err; err != nil {
+			log.Error("Collector ", name, " failed to run : ", err)
+			failed = append(failed, name)
+			success = "0"
 		}
+		ts := labels{"{#COLLECTORNAME}": name}
+		duration := strconv.FormatInt(int64(results[i].duration/time.Millisecond), 10)
+		add("dell.hardware.collector", name, "success", success, ts, descDellHWCollector)
+		add("dell.hardware.collector", name, "duration_ms", duration, ts, descDellHWCollectorTime)
 	}
 
 	// add the number of each hardware components : How many processors, physical disks, etc.
 	reportCounts()
 	reportStatuses()
+	if len(failed) > 0 {
+		return fmt.Errorf("collectors failed : %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+var (
+	defaultCollectorTimeout = 30 * time.Second
+	collectorTimeouts       = map[string]time.Duration{}
+)
+
+// collectorTimeout returns the omreport run time after which the calls of the
+// named collector are interrupted. Time spent waiting for --parallel does not
+// count.
+func collectorTimeout(name string) time.Duration {
+	if timeout, ok := collectorTimeouts[name]; ok {
+		return timeout
+	}
+	return defaultCollectorTimeout
+}
+
+// parseCollectorTimeouts reads a comma-separated list of a default timeout
+// and name=timeout overrides, such as "30s,storage_controller=2m". Names must
+// be those of collectors.
+func parseCollectorTimeouts(s string, collectors map[string]collector) error {
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		kv := strings.SplitN(part, "=", 2)
+		timeout, err := time.ParseDuration(strings.TrimSpace(kv[len(kv)-1]))
+		if err != nil || timeout <= 0 {
+			return fmt.Errorf("invalid collector timeout %q, expected a duration or name=duration", part)
+		}
+		if len(kv) == 1 {
+			defaultCollectorTimeout = timeout
+			continue
+		}
+		name := strings.TrimSpace(kv[0])
+		if _, ok := collectors[name]; !ok {
+			return fmt.Errorf("unknown collector %q in collector timeouts, valid collectors are : %s", name, strings.Join(collectorNames(collectors), ", "))
+		}
+		collectorTimeouts[name] = timeout
+	}
 	return nil
 }
 
@@ -88,29 +454,92 @@ func reportStatuses() {
 		strSum := strconv.Itoa(sum)
 		add(prefix, "", "status_sum", strSum, labels{}, "Sum of component statuses of type "+componentType)
 	}
+
+	// the worst status of each component type, and of the whole host
+	health, found := 0, false
+	for prefix, max := range metricMaxStatuses {
+		componentType := getComponentType(prefix)
+		strMax := strconv.Itoa(max)
+		add(prefix, "", "status_max", strMax, labels{}, "Worst component status of type "+componentType)
+		if !found || max > health {
+			health, found = max, true
+		}
+	}
+	if found {
+		add("dell.hardware", "", "health", strconv.Itoa(health), labels{}, "Worst component status of the host")
+	}
+}
+
+// errDeadline is returned by Report once the collector ran out of time.
+var errDeadline = errors.New("collector timeout exceeded")
+
+// newOmReport returns an omReport whose calls run when one of slots is free,
+// and are interrupted when ctx is done or after timeout of run time, if not 0.
+func newOmReport(ctx context.Context, timeout time.Duration, slots chan struct{}) *omReport {
+	return &omReport{ctx: ctx, timeout: timeout, slots: slots}
 }
 
-func newOmReport() *omReport {
-	return &omReport{}
+// runTime returns the time spent running omreport and parsing its output,
+// leaving out the time spent waiting for a slot. Calls running at the same
+// time all count.
+func (o *omReport) runTime() time.Duration {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.elapsed
 }
 
-func (o *omReport) Report(f func([]string), args ...string) {
-	args = append(args, "-fmt", "ssv")
-	_ = readCommand(func(line string) error {
-		sp := strings.Split(line, ";")
-		for i, s := range sp {
-			sp[i] = clean(s)
+func (o *omReport) addRunTime(d time.Duration) {
+	o.mu.Lock()
+	o.elapsed += d
+	o.mu.Unlock()
+}
+
+// Report runs omreport with args and calls f with the record of each object
+// element of its XML output. It returns an error if omreport could not be run
+// or its output could not be parsed.
+func (o *omReport) Report(object string, f func(omRecord), args ...string) error {
+	return o.ReportObjects(map[string]func(omRecord){object: f}, args...)
+}
+
+// ReportObjects is the same as Report for several objects of the same output,
+// calling the function of fs named after each object element. omreport is
+// run once.
+func (o *omReport) ReportObjects(fs map[string]func(omRecord), args ...string) error {
+	args = append(args, "-fmt", "xml")
+	if o.slots != nil {
+		select {
+		case o.slots <- struct{}{}:
+			defer func() { <-o.slots }()
+		case <-o.ctx.Done():
 		}
-		f(sp)
-		return nil
-	}, "/opt/dell/srvadmin/bin/omreport", args...)
+	}
+	ctx := o.ctx
+	if o.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, o.timeout-o.runTime())
+		defer cancel()
+	}
+	if ctx.Err() != nil {
+		return fmt.Errorf("omreport %s : %v", strings.Join(args, " "), errDeadline)
+	}
+	start := time.Now()
+	defer func() { o.addRunTime(time.Since(start)) }()
+	b, err := readCommandOutput(ctx, "/opt/dell/srvadmin/bin/omreport", args...)
+	if err != nil {
+		return fmt.Errorf("omreport %s : %v", strings.Join(args, " "), err)
+	}
+	if err := parseOmXML(b, fs); err != nil {
+		return fmt.Errorf("parsing omreport %s output : %v", strings.Join(args, " "), err)
+	}
+	return nil
 }
 
 type omReporter interface {
-	Report(f func([]string), args ...string)
+	Report(object string, f func(omRecord), args ...string) error
+	ReportObjects(fs map[string]func(omRecord), args ...string) error
 }
 
-func add(prefix string, name string, metricType string, value string, t labels, desc string) {
+func newItem(prefix string, name string, metricType string, value string, t labels, desc string) *zabbixItem {
 	var fullyQualifiedMetricName string
 	if name == "" {
 		fullyQualifiedMetricName = fmt.Sprintf("%s[%s]", prefix, metricType)
@@ -118,6 +547,17 @@ func add(prefix string, name string, metricType string, value string, t labels,
 		fullyQualifiedMetricName = fmt.Sprintf("%s[%s,%s]", prefix, name, metricType)
 	}
 	metric := newZabbixItem(fullyQualifiedMetricName, t, value, desc)
+	metric.Prefix = prefix
+	metric.Component = name
+	metric.Type = metricType
+	return metric
+}
+
+func add(prefix string, name string, metricType string, value string, t labels, desc string) {
+	metric := newItem(prefix, name, metricType, value, t, desc)
+	fullyQualifiedMetricName := metric.Name
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
 	cache.metrics[fullyQualifiedMetricName] = *metric
 	if metricType == "status" {
 		metricCounts[prefix]++
@@ -125,230 +565,583 @@ func add(prefix string, name string, metricType string, value string, t labels,
 		if err != nil {
 			log.Error("Parsing status on metric", fullyQualifiedMetricName)
 		}
-		metricStatuses[prefix] += statusLevel
+		if statusLevel > 0 {
+			metricStatuses[prefix] += statusLevel
+		} else if _, ok := metricStatuses[prefix]; !ok {
+			metricStatuses[prefix] = 0
+		}
+		if max, ok := metricMaxStatuses[prefix]; !ok || statusLevel > max {
+			metricMaxStatuses[prefix] = statusLevel
+		}
 	}
 
 }
 
+// addStatus adds the status item of a component from its omreport status word.
+// With --status-text, it also adds a status_text item holding the word itself
+// and the component state, such as "Non-Critical (Rebuilding)".
+func addStatus(prefix string, name string, status string, state string, t labels, desc string) {
+	add(prefix, name, "status", severity(status), t, desc)
+	if !statusText {
+		return
+	}
+	text := status
+	if state != "" {
+		text += " (" + state + ")"
+	}
+	addText(prefix, name, "status_text", text, t, descDellHWStatusText)
+}
+
+// addText adds an item holding text, such as a version or a state name. Text
+// items are never counted as component statuses.
+func addText(prefix string, name string, metricType string, value string, t labels, desc string) {
+	metric := newItem(prefix, name, metricType, value, t, desc)
+	metric.Text = true
+	cache.set(*metric)
+}
+
 func dummyReport(om omReporter) error {
 	add("dummy", "", "status", "1", labels{"#{FUNKY}": "lolilol"}, "Dummy description")
 	return nil
 }
 
 func omreportChassis(om omReporter) error {
-	om.Report(func(fields []string) {
-		if len(fields) != 2 || fields[0] == "SEVERITY" {
-			return
-		}
-		component := strings.Replace(fields[1], " ", "_", -1)
-		add("dell.hardware.chassis", "", "status", severity(fields[0]), labels{"component": component}, descDellHWChassis)
+	return om.Report("Component", func(r omRecord) {
+		component := strings.Replace(r["ObjName"], " ", "_", -1)
+		ts := labels{"{#CHASSISCOMPONENT}": component}
+		addStatus("dell.hardware.chassis", component, r.status("ObjStatus"), "", ts, descDellHWChassis)
 	}, "chassis")
-	return nil
 }
 
 func omreportSystem(om omReporter) error {
-	om.Report(func(fields []string) {
-		if len(fields) != 2 || fields[0] == "SEVERITY" {
-			return
-		}
-		component := strings.Replace(fields[1], " ", "_", -1)
-		add("dell.hardware.system", "", "status", severity(fields[0]), labels{"component": component}, descDellHWSystem)
+	return om.Report("Component", func(r omRecord) {
+		component := strings.Replace(r["ObjName"], " ", "_", -1)
+		ts := labels{"{#SYSTEMCOMPONENT}": component}
+		addStatus("dell.hardware.system", component, r.status("ObjStatus"), "", ts, descDellHWSystem)
 	}, "system")
-	return nil
 }
 
-func omreportStorageEnclosure(om omReporter) error {
-	om.Report(func(fields []string) {
-		if len(fields) < 3 || fields[0] == "ID" {
-			return
+// storageID builds the omreport text output id of a storage object, e.g. 0_1_3
+// for physical disk 3 of enclosure 1 on connector 0, from the given fields.
+func storageID(r omRecord, fields ...string) string {
+	parts := []string{}
+	for _, field := range fields {
+		if r[field] != "" {
+			parts = append(parts, r[field])
 		}
-		id := strings.Replace(fields[0], ":", "_", -1)
-		add("dell.hardware.storage.enclosure", "", "status", severity(fields[1]), labels{"id": id}, descDellHWStorageEnc)
+	}
+	return strings.Join(parts, "_")
+}
+
+func omreportStorageEnclosure(om omReporter) error {
+	return om.Report("DCStorageObject", func(r omRecord) {
+		id := storageID(r, "Channel", "EnclosureID")
+		ts := labels{"{#ENCLOSURESLOT}": id}
+		addStatus("dell.hardware.storage.enclosure", id, r.status("ObjStatus"), "", ts, descDellHWStorageEnc)
 	}, "storage", "enclosure")
-	return nil
 }
 
+var (
+	vdiskLayouts = map[string]string{
+		"1":     "Concatenated",
+		"2":     "RAID-0",
+		"4":     "RAID-1",
+		"64":    "RAID-5",
+		"128":   "RAID-6",
+		"2048":  "RAID-10",
+		"8192":  "RAID-50",
+		"16384": "RAID-60",
+	}
+	vdiskReadPolicies  = map[string]string{"1": "Read Ahead", "2": "No Read Ahead", "4": "Adaptive Read Ahead"}
+	vdiskWritePolicies = map[string]string{"1": "Write Back", "2": "Write Through", "4": "Force Write Back"}
+	vdiskDiskCaches    = map[string]string{"1": "Enabled", "2": "Disabled", "3": "Default"}
+)
+
 func omreportStorageVdisk(om omReporter) error {
-	om.Report(func(fields []string) {
-		if len(fields) < 3 || fields[0] == "ID" {
-			return
-		}
-		id := strings.Replace(fields[0], ":", "_", -1)
+	return om.Report("DCStorageObject", func(r omRecord) {
+		id := storageID(r, "DeviceID")
 		ts := labels{"{#LOGICALDRIVESLOT}": id}
-		add("dell.hardware.raid.logicaldrive", id, "status", severity(fields[1]), ts, descDellHWVDisk)
+		prefix := "dell.hardware.raid.logicaldrive"
+		addStatus(prefix, id, r.status("ObjStatus"), r.enum("ObjState", omStorageStates), ts, descDellHWVDisk)
+		if r["ObjState"] != "" {
+			addText(prefix, id, "state", r.enum("ObjState", omStorageStates), ts, descDellHWVDiskState)
+		}
+		if r["Layout"] != "" {
+			addText(prefix, id, "layout", r.enum("Layout", vdiskLayouts), ts, descDellHWVDiskLayout)
+		}
+		if size, ok := r.scaled("Length", 1); ok {
+			add(prefix, id, "size", size, ts, descDellHWVDiskSize)
+		}
+		if r["ReadPolicy"] != "" {
+			addText(prefix, id, "read_policy", r.enum("ReadPolicy", vdiskReadPolicies), ts, descDellHWVDiskRead)
+		}
+		if r["WritePolicy"] != "" {
+			addText(prefix, id, "write_policy", r.enum("WritePolicy", vdiskWritePolicies), ts, descDellHWVDiskWrite)
+		}
+		if r["DiskCachePolicy"] != "" {
+			addText(prefix, id, "disk_cache_policy", r.enum("DiskCachePolicy", vdiskDiskCaches), ts, descDellHWVDiskCache)
+		}
+		if progress, ok := r.percent("Progress"); ok {
+			add(prefix, id, "progress", progress, ts, descDellHWVDiskProgress)
+		}
 	}, "storage", "vdisk")
-	return nil
 }
 
 func omreportPs(om omReporter) error {
-	om.Report(func(fields []string) {
-		if len(fields) < 3 || fields[0] == "Index" {
-			return
-		}
-		id := strings.Replace(fields[0], ":", "_", -1)
+	return om.Report("PowerSupply", func(r omRecord) {
+		id := r["index"]
 		ts := labels{"{#POWERSLOT}": id}
-		add("dell.hardware.power", id, "status", severity(fields[1]), ts, descDellHWPS)
-		if len(fields) < 6 {
-			return
-		}
-		if fields[4] != "" {
-			iWattage, err := extract(fields[4], "W")
-			if err == nil {
-				add("dell.hardware.power", id, "input_watts", iWattage, ts, descDellHWPS)
-			}
+		addStatus("dell.hardware.power", id, r.status("ObjStatus"), "", ts, descDellHWPS)
+		if iWattage, ok := r.scaled("InputRatedWatts", 1); ok {
+			add("dell.hardware.power", id, "input_watts", iWattage, ts, descDellHWPS)
 		}
-		if fields[5] != "" {
-			oWattage, err := extract(fields[5], "W")
-			if err == nil {
-				add("dell.hardware.power", id, "output_watts", oWattage, ts, descDellHWPS)
-			}
+		if oWattage, ok := r.scaled("OutputWatts", 1); ok {
+			add("dell.hardware.power", id, "output_watts", oWattage, ts, descDellHWPS)
 		}
 	}, "chassis", "pwrsupplies")
-	return nil
 }
 
 func omreportPsAmpsSysboardPwr(om omReporter) error {
-	om.Report(func(fields []string) {
-		if len(fields) == 2 && strings.Contains(fields[0], "Current") {
-			iFields := strings.Split(fields[0], "Current")
-			vFields := strings.Fields(fields[1])
-			if len(iFields) < 2 && len(vFields) < 2 {
+	probe := func(r omRecord) {
+		location := r["ProbeLocation"]
+		if strings.Contains(location, "Current") {
+			// Amperage is given in tenths of Amps.
+			reading, ok := r.scaled("ProbeReading", 10)
+			if !ok {
 				return
 			}
-			id := strings.Replace(iFields[0], " ", "", -1)
-			add("dell.hardware.chassis.current", "", "reading", vFields[0], labels{"id": id}, descDellHWCurrent)
-		} else if len(fields) == 6 && (fields[2] == "System Board Pwr Consumption" || fields[2] == "System Board System Level") {
-			vFields := strings.Fields(fields[3])
-			warnFields := strings.Fields(fields[4])
-			failFields := strings.Fields(fields[5])
-			if len(vFields) < 2 || len(warnFields) < 2 || len(failFields) < 2 {
+			id := strings.Replace(strings.Split(location, "Current")[0], " ", "", -1)
+			add("dell.hardware.chassis.current", "", "reading", reading, labels{"id": id}, descDellHWCurrent)
+		} else if location == "System Board Pwr Consumption" || location == "System Board System Level" {
+			reading, rOk := r.scaled("ProbeReading", 1)
+			warn, wOk := r.scaled("ProbeThresholds.UNCThreshold", 1)
+			fail, fOk := r.scaled("ProbeThresholds.UCThreshold", 1)
+			if !rOk || !wOk || !fOk {
 				return
 			}
-			add("dell.hardware.chassis.power", "", "reading", vFields[0], nil, descDellHWPower)
-			add("dell.hardware.chassis.power.warn", "", "level", warnFields[0], nil, descDellHWPowerThreshold)
-			add("dell.hardware.chassis.power.fail", "", "level", failFields[0], nil, descDellHWPowerThreshold)
+			add("dell.hardware.chassis.power", "", "reading", reading, nil, descDellHWPower)
+			add("dell.hardware.chassis.power.warn", "", "level", warn, nil, descDellHWPowerThreshold)
+			add("dell.hardware.chassis.power.fail", "", "level", fail, nil, descDellHWPowerThreshold)
 		}
-	}, "chassis", "pwrmonitoring")
-	return nil
+	}
+	consumption := func(r omRecord) {
+		prefix := "dell.hardware.chassis.power"
+		// Energy is given in Watt-hours, amperage in tenths of Amps.
+		history := []struct {
+			metricType, field string
+			divisor           float64
+			desc              string
+		}{
+			{"energy", "CumulativeEnergy", 1000, descDellHWEnergy},
+			{"energy_start", "CumulativeEnergyStartTime", 1, descDellHWEnergyStart},
+			{"peak", "PeakPower", 1, descDellHWPeakPower},
+			{"peak_time", "PeakPowerTime", 1, descDellHWPeakPowerTime},
+			{"peak_amps", "PeakAmperage", 10, descDellHWPeakAmps},
+			{"peak_amps_time", "PeakAmperageTime", 1, descDellHWPeakAmpsTime},
+			{"avg_minute", "AvgPowerLastMinute", 1, descDellHWAvgPower},
+			{"avg_hour", "AvgPowerLastHour", 1, descDellHWAvgPower},
+			{"avg_day", "AvgPowerLastDay", 1, descDellHWAvgPower},
+			{"avg_week", "AvgPowerLastWeek", 1, descDellHWAvgPower},
+		}
+		for _, h := range history {
+			if value, ok := r.scaled(h.field, h.divisor); ok {
+				add(prefix, "", h.metricType, value, nil, h.desc)
+			}
+		}
+	}
+	return om.ReportObjects(map[string]func(omRecord){"CurrentProbe": probe, "PowerConsumptionData": consumption}, "chassis", "pwrmonitoring")
 }
 
+var (
+	batteryStates = map[string]string{
+		"1":   "Ready",
+		"2":   "Failed",
+		"32":  "Degraded",
+		"64":  "Charging",
+		"128": "Learning",
+	}
+	batteryCapacityStatuses = map[string]string{"1": "Ready", "2": "Failed", "3": "Unknown"}
+	batteryLearnModes       = map[string]string{"1": "Auto", "2": "Warn only", "4": "Disabled"}
+)
+
 func omreportStorageBattery(om omReporter) error {
-	om.Report(func(fields []string) {
-		if len(fields) < 3 || fields[0] == "ID" {
-			return
+	return om.Report("DCStorageObject", func(r omRecord) {
+		id := storageID(r, "DeviceID")
+		ts := labels{"{#BATTERYSLOT}": id}
+		prefix := "dell.hardware.storage.battery"
+		addStatus(prefix, id, r.status("ObjStatus"), r.enum("ObjState", batteryStates), ts, descDellHWStorageBattery)
+		if r["ObjState"] != "" {
+			state := r.enum("ObjState", batteryStates)
+			addText(prefix, id, "state", state, ts, descDellHWBatteryState)
+			learning := "0"
+			if state == "Learning" {
+				learning = "1"
+			}
+			add(prefix, id, "learning", learning, ts, descDellHWBatteryLearn)
+		}
+		if r["PredictedCapacityStatus"] != "" {
+			addText(prefix, id, "predicted_capacity", r.enum("PredictedCapacityStatus", batteryCapacityStatuses), ts, descDellHWBatteryCap)
+		}
+		if r["LearnMode"] != "" {
+			addText(prefix, id, "learn_mode", r.enum("LearnMode", batteryLearnModes), ts, descDellHWBatteryMode)
+		}
+		if nextLearn, ok := r.scaled("NextLearnTime", 1); ok {
+			add(prefix, id, "next_learn_time", nextLearn, ts, descDellHWBatteryNext)
+		}
+		if maxDelay, ok := r.scaled("MaxLearnDelay", 1); ok {
+			add(prefix, id, "max_learn_delay", maxDelay, ts, descDellHWBatteryDelay)
 		}
-		id := strings.Replace(fields[0], ":", "_", -1)
-		add("dell.hardware.storage.battery", "", "status", severity(fields[1]), labels{"id": id}, descDellHWStorageBattery)
 	}, "storage", "battery")
-	return nil
 }
 
+var (
+	controllerPatrolStates = map[string]string{"1": "Stopped", "2": "Ready", "4": "Active"}
+	controllerPatrolModes  = map[string]string{"1": "Disabled", "2": "Auto", "3": "Manual"}
+)
+
 func omreportStorageController(om omReporter) error {
-	om.Report(func(fields []string) {
-		if len(fields) < 3 || fields[0] == "ID" {
-			return
-		}
-		omreportStoragePdisk(om, fields[0])
-		id := strings.Replace(fields[0], ":", "_", -1)
+	ids := []string{}
+	err := om.Report("DCStorageObject", func(r omRecord) {
+		id := storageID(r, "ControllerNum")
+		ids = append(ids, id)
 		ts := labels{"{#CONTROLLERSLOT}": id}
-		add("dell.hardware.raid.controller", id, "status", severity(fields[1]), ts, descDellHWStorageCtl)
+		prefix := "dell.hardware.raid.controller"
+		addStatus(prefix, id, r.status("ObjStatus"), "", ts, descDellHWStorageCtl)
+		if r["FirmwareVer"] != "" {
+			addText(prefix, id, "firmware_version", r["FirmwareVer"], ts, descDellHWCtlFirmware)
+		}
+		if r["DriverVersion"] != "" {
+			addText(prefix, id, "driver_version", r["DriverVersion"], ts, descDellHWCtlDriver)
+		}
+		if cacheSize, ok := r.scaled("CacheSize", 1); ok {
+			add(prefix, id, "cache_size", cacheSize, ts, descDellHWCtlCache)
+		}
+		if r["PatrolReadState"] != "" {
+			addText(prefix, id, "patrol_read_state", r.enum("PatrolReadState", controllerPatrolStates), ts, descDellHWCtlPatrolState)
+		}
+		if r["PatrolReadMode"] != "" {
+			addText(prefix, id, "patrol_read_mode", r.enum("PatrolReadMode", controllerPatrolModes), ts, descDellHWCtlPatrolMode)
+		}
+		if rebuildRate, ok := r.percent("RebuildRate"); ok {
+			add(prefix, id, "rebuild_rate", rebuildRate, ts, descDellHWCtlRebuildRate)
+		}
+		if minimum := r["MinRequiredFirmwareVersion"]; minimum != "" && minimum != "Not Applicable" {
+			addText(prefix, id, "min_required_firmware_version", minimum, ts, descDellHWCtlMinFirmware)
+		}
+		if minimum := r["MinRequiredDriverVersion"]; minimum != "" && minimum != "Not Applicable" {
+			addText(prefix, id, "min_required_driver_version", minimum, ts, descDellHWCtlMinDriver)
+		}
+		outdated := "0"
+		if belowMinimum(r["FirmwareVer"], r["MinRequiredFirmwareVersion"]) || belowMinimum(r["DriverVersion"], r["MinRequiredDriverVersion"]) {
+			outdated = "1"
+		}
+		add(prefix, id, "outdated", outdated, ts, descDellHWCtlOutdated)
 	}, "storage", "controller")
-	return nil
+
+	// Physical disks are listed per controller, which takes a while on each,
+	// so the controllers are queried at the same time, within --parallel.
+	errPdisks := make([]error, len(ids))
+	var wg sync.WaitGroup
+	for i, id := range ids {
+		wg.Add(1)
+		go func(i int, id string) {
+			defer wg.Done()
+			errPdisks[i] = omreportStoragePdisk(om, id)
+		}(i, id)
+	}
+	wg.Wait()
+	return firstError(append([]error{err}, errPdisks...)...)
+}
+
+// belowMinimum returns true if version is older than minimum. omreport only
+// fills the minimum required version when the installed one is too old, and
+// sets it to "Not Applicable" otherwise. A missing version is not reported as
+// outdated.
+func belowMinimum(version string, minimum string) bool {
+	if version == "" || minimum == "" || minimum == "Not Applicable" {
+		return false
+	}
+	return compareVersions(version, minimum) < 0
 }
 
+var (
+	pdiskBusProtocols = map[string]string{"1": "SCSI", "7": "SATA", "8": "SAS", "9": "PCIe"}
+	pdiskMedias       = map[string]string{"1": "HDD", "2": "SSD"}
+	pdiskHotSpares    = map[string]string{"0": "No", "1": "Dedicated", "2": "Global"}
+)
+
 // omreportStoragePdisk is called from the controller func, since it needs the encapsulating id.
-func omreportStoragePdisk(om omReporter, id string) {
-	om.Report(func(fields []string) {
-		if len(fields) < 3 || fields[0] == "ID" {
-			return
-		}
-		//Need to find out what the various ID formats might be
-		diskID := strings.Replace(fields[0], ":", "_", -1)
+// It may run concurrently for several controllers.
+func omreportStoragePdisk(om omReporter, id string) error {
+	return om.Report("DCStorageObject", func(r omRecord) {
+		diskID := storageID(r, "Channel", "EnclosureID", "TargetID")
 		ts := labels{"{#PHYSICALDRIVESLOT}": diskID, "{#CONTROLLERSLOT}": id}
-		add("dell.hardware.raid.physicaldrive", diskID, "status", severity(fields[1]), ts, descDellHWPDisk)
+		prefix := "dell.hardware.raid.physicaldrive"
+		addStatus(prefix, diskID, r.status("ObjStatus"), r.enum("ObjState", omStorageStates), ts, descDellHWPDisk)
+		if r["ObjState"] != "" {
+			addText(prefix, diskID, "state", r.enum("ObjState", omStorageStates), ts, descDellHWPDiskState)
+		}
+		if predicted, ok := r.flag("FailurePredicted"); ok {
+			add(prefix, diskID, "failure_predicted", predicted, ts, descDellHWPDiskPredicted)
+		}
+		if progress, ok := r.percent("Progress"); ok {
+			add(prefix, diskID, "progress", progress, ts, descDellHWPDiskProgress)
+		}
+		if r["BusProtocol"] != "" {
+			addText(prefix, diskID, "bus_protocol", r.enum("BusProtocol", pdiskBusProtocols), ts, descDellHWPDiskProtocol)
+		}
+		if r["MediaType"] != "" {
+			addText(prefix, diskID, "media", r.enum("MediaType", pdiskMedias), ts, descDellHWPDiskMedia)
+		}
+		if capacity, ok := r.scaled("Length", 1); ok {
+			add(prefix, diskID, "capacity", capacity, ts, descDellHWPDiskCapacity)
+		}
+		if used, ok := r.scaled("UsedSpace", 1); ok {
+			add(prefix, diskID, "used_space", used, ts, descDellHWPDiskUsed)
+		}
+		if r["HotSpareStatus"] != "" {
+			addText(prefix, diskID, "hot_spare", r.enum("HotSpareStatus", pdiskHotSpares), ts, descDellHWPDiskHotSpare)
+		}
+		if endurance, ok := r.percent("RemainingRatedWriteEndurance"); ok {
+			add(prefix, diskID, "write_endurance", endurance, ts, descDellHWPDiskEndurance)
+		}
 	}, "storage", "pdisk", "controller="+id)
 }
 
+var processorStates = map[string]string{"1": "Present", "2": "Disabled", "4": "Idle"}
+
 func omreportProcessors(om omReporter) error {
-	om.Report(func(fields []string) {
-		if len(fields) != 8 {
-			return
+	processors := []omRecord{}
+	err := om.Report("DevProcessor", func(r omRecord) {
+		processors = append(processors, r)
+	}, "chassis", "processors")
+	if err != nil {
+		return err
+	}
+
+	// CPUs of a system are identical, so the most cores and highest speed found
+	// are what every CPU should report.
+	maxCores, maxSpeed := 0, 0
+	for _, r := range processors {
+		if cores, err := strconv.Atoi(r["CoreCount"]); err == nil && cores > maxCores {
+			maxCores = cores
 		}
-		if _, err := strconv.Atoi(fields[0]); err != nil {
-			return
+		if speed, err := strconv.Atoi(r["CurSpeed"]); err == nil && speed > maxSpeed {
+			maxSpeed = speed
 		}
-		pname := replace(fields[2])
+	}
+
+	for _, r := range processors {
+		pname := replace(r["ConnectorName"])
 		ts := labels{"{#PROCESSORNAME}": pname}
-		add("dell.hardware.processors", pname, "status", severity(fields[1]), ts, descDellHWCPU)
-	}, "chassis", "processors")
+		prefix := "dell.hardware.processors"
+		addStatus(prefix, pname, r.status("ObjStatus"), r.enum("State", processorStates), ts, descDellHWCPU)
+		if r["Brand"] != "" {
+			addText(prefix, pname, "brand", r["Brand"], ts, descDellHWCPUBrand)
+		}
+		if r["State"] != "" {
+			addText(prefix, pname, "state", r.enum("State", processorStates), ts, descDellHWCPUState)
+		}
+		if maxSpeedMHz, ok := r.scaled("MaxSpeed", 1); ok {
+			add(prefix, pname, "max_speed", maxSpeedMHz, ts, descDellHWCPUMaxSpeed)
+		}
+		mismatch := "0"
+		if speed, err := strconv.Atoi(r["CurSpeed"]); err == nil {
+			add(prefix, pname, "speed", r["CurSpeed"], ts, descDellHWCPUSpeed)
+			if speed < maxSpeed {
+				mismatch = "1"
+			}
+		}
+		if cores, err := strconv.Atoi(r["CoreCount"]); err == nil {
+			add(prefix, pname, "cores", r["CoreCount"], ts, descDellHWCPUCores)
+			if cores < maxCores {
+				mismatch = "1"
+			}
+		}
+		add(prefix, pname, "mismatch", mismatch, ts, descDellHWCPUMismatch)
+	}
 	return nil
 }
 
 func omreportFans(om omReporter) error {
-	om.Report(func(fields []string) {
-		if len(fields) != 8 {
-			return
-		}
-		if _, err := strconv.Atoi(fields[0]); err != nil {
-			return
-		}
-		fanName := fields[2]
+	return om.Report("Fan", func(r omRecord) {
+		fanName := r["ProbeLocation"]
 		ts := labels{"{#FANNAME}": fanName}
-		add("dell.hardware.fan", fanName, "status", severity(fields[1]), ts, descDellHWFan)
-		fs := strings.Fields(fields[3])
-		if len(fs) == 2 && fs[1] == "RPM" {
-			add("dell.hardware.fan", fanName, "speed", fs[0], ts, descDellHWFanSpeed)
+		addStatus("dell.hardware.fan", fanName, r.status("ProbeStatus"), "", ts, descDellHWFan)
+		if speed, ok := r.scaled("ProbeReading", 1); ok {
+			add("dell.hardware.fan", fanName, "speed", speed, ts, descDellHWFanSpeed)
 		}
+		addThresholds("dell.hardware.fan", fanName, r, 1, ts)
 	}, "chassis", "fans")
-	return nil
+}
+
+// memoryTypes maps the SMBIOS memory device types to their names.
+var memoryTypes = map[string]string{
+	"18": "DDR",
+	"19": "DDR2",
+	"24": "DDR3",
+	"26": "DDR4",
+	"34": "DDR5",
 }
 
 func omreportMemory(om omReporter) error {
-	om.Report(func(fields []string) {
-		if len(fields) != 5 {
-			return
+	dimm := func(r omRecord) {
+		slot := replace(r["DeviceLocator"])
+		ts := labels{"{#MEMORYSLOT}": slot}
+		addStatus("dell.hardware.memory", slot, r.status("ObjStatus"), "", ts, descDellHWMemory)
+		if size, ok := r.scaled("Size", 1); ok {
+			add("dell.hardware.memory", slot, "size", size, ts, descDellHWMemorySize)
 		}
-		if _, err := strconv.Atoi(fields[0]); err != nil {
-			return
+		if r["Type"] != "" {
+			addText("dell.hardware.memory", slot, "type", r.enum("Type", memoryTypes), ts, descDellHWMemoryType)
 		}
-		slot := replace(fields[2])
-		ts := labels{"{#MEMORYSLOT}": slot}
-		add("dell.hardware.memory", slot, "status", severity(fields[1]), ts, descDellHWMemory)
-	}, "chassis", "memory")
-	return nil
+		if speed, ok := r.scaled("Speed", 1); ok {
+			add("dell.hardware.memory", slot, "speed", speed, ts, descDellHWMemorySpeed)
+		}
+	}
+	// The memory totals are fields of the whole document.
+	totals := func(r omRecord) {
+		installed, iOk := r.scaled("MemoryInfo.TotalInstalledCapacity", 1)
+		available, aOk := r.scaled("MemoryInfo.TotalAvailableCapacity", 1)
+		if iOk {
+			add("dell.hardware.memory", "", "installed", installed, nil, descDellHWMemoryTotal)
+		}
+		if aOk {
+			add("dell.hardware.memory", "", "available", available, nil, descDellHWMemoryOS)
+		}
+		if iOk && aOk {
+			i, _ := strconv.ParseFloat(installed, 64)
+			a, _ := strconv.ParseFloat(available, 64)
+			add("dell.hardware.memory", "", "unavailable", strconv.FormatFloat(i-a, 'f', -1, 64), nil, descDellHWMemoryMissing)
+		}
+		if r["MemoryInfo.RedundancyConfiguration"] != "" {
+			addText("dell.hardware.memory", "", "redundancy", r["MemoryInfo.RedundancyConfiguration"], nil, descDellHWMemoryRedMode)
+		}
+		if r["MemoryInfo.RedundancyStatus"] != "" {
+			addText("dell.hardware.memory", "", "redundancy_status", r["MemoryInfo.RedundancyStatus"], nil, descDellHWMemoryRedState)
+		}
+	}
+	return om.ReportObjects(map[string]func(omRecord){"MemDevObj": dimm, "OMA": totals}, "chassis", "memory")
 }
 
 func omreportTemps(om omReporter) error {
-	om.Report(func(fields []string) {
-		if len(fields) != 8 {
-			return
-		}
-		if _, err := strconv.Atoi(fields[0]); err != nil {
-			return
-		}
-		ts := labels{"name": replace(fields[2])}
-		add("dell.hardware.chassis.temps", "", "status", severity(fields[1]), ts, descDellHWTemp)
-		fs := strings.Fields(fields[3])
-		if len(fs) == 2 && fs[1] == "C" {
-			add("dell.hardware.chassis.temps", "", "reading", fs[0], ts, descDellHWTempReadings)
+	return om.Report("TemperatureProbe", func(r omRecord) {
+		name := replace(r["ProbeLocation"])
+		ts := labels{"{#TEMPNAME}": name}
+		addStatus("dell.hardware.chassis.temps", name, r.status("ProbeStatus"), "", ts, descDellHWTemp)
+		// Temperatures are given in tenths of degrees Celsius.
+		if reading, ok := r.scaled("ProbeReading", 10); ok {
+			add("dell.hardware.chassis.temps", name, "reading", reading, ts, descDellHWTempReadings)
 		}
+		addThresholds("dell.hardware.chassis.temps", name, r, 10, ts)
 	}, "chassis", "temps")
-	return nil
 }
 
 func omreportVolts(om omReporter) error {
-	om.Report(func(fields []string) {
-		if len(fields) != 8 {
+	return om.Report("VoltageProbe", func(r omRecord) {
+		name := replace(r["ProbeLocation"])
+		ts := labels{"{#VOLTNAME}": name}
+		addStatus("dell.hardware.chassis.volts", name, r.status("ProbeStatus"), "", ts, descDellHWVolt)
+		// Voltages are given in millivolts. Discrete probes have no reading.
+		if reading, ok := r.scaled("ProbeReading", 1000); ok {
+			add("dell.hardware.chassis.volts", name, "reading", reading, ts, descDellHWVoltReadings)
+		}
+		addThresholds("dell.hardware.chassis.volts", name, r, 1000, ts)
+	}, "chassis", "volts")
+}
+
+// addThresholds adds the warning and failure thresholds of a probe, which are
+// given in the same unit as its reading.
+func addThresholds(prefix string, name string, r omRecord, divisor float64, t labels) {
+	thresholds := []struct{ metricType, field string }{
+		{"warn_min", "ProbeThresholds.LNCThreshold"},
+		{"warn_max", "ProbeThresholds.UNCThreshold"},
+		{"fail_min", "ProbeThresholds.LCThreshold"},
+		{"fail_max", "ProbeThresholds.UCThreshold"},
+	}
+	for _, th := range thresholds {
+		if value, ok := r.threshold(th.field, divisor); ok {
+			add(prefix, name, th.metricType, value, t, descDellHWThreshold)
+		}
+	}
+}
+
+var nicConnectionStatuses = map[string]string{"1": "Connected", "2": "Disconnected"}
+
+func omreportNics(om omReporter) error {
+	report := func(r omRecord) {
+		nicName := r["InterfaceName"]
+		ts := labels{"{#NICNAME}": nicName}
+		addStatus("dell.hardware.nic", nicName, r.status("ObjStatus"), r.enum("ConnectionStatus", nicConnectionStatuses), ts, descDellHWNic)
+		if r["ConnectionStatus"] != "" {
+			link := "0"
+			if r.enum("ConnectionStatus", nicConnectionStatuses) == "Connected" {
+				link = "1"
+			}
+			add("dell.hardware.nic", nicName, "link", link, ts, descDellHWNicLink)
+		}
+		if speed, ok := r.scaled("LinkSpeed", 1); ok {
+			add("dell.hardware.nic", nicName, "speed", speed, ts, descDellHWNicSpeed)
+		}
+	}
+	return om.ReportObjects(map[string]func(omRecord){"NIC": report, "TeamInterface": report}, "chassis", "nics")
+}
+
+// omreportFirmware reports the version of every component listed by omreport,
+// and the BIOS, BMC and lifecycle controller versions under fixed keys since
+// their component names change between PowerEdge generations.
+func omreportFirmware(om omReporter) error {
+	errBios := om.Report("BIOS", func(r omRecord) {
+		if r["Version"] != "" {
+			addText("dell.hardware.inventory", "", "bios_version", r["Version"], nil, descDellHWBiosVersion)
+		}
+		if r["ReleaseDate"] != "" {
+			addText("dell.hardware.inventory", "", "bios_release_date", r["ReleaseDate"], nil, descDellHWBiosDate)
+		}
+	}, "chassis", "bios")
+	errVersions := om.Report("VersionInfo", func(r omRecord) {
+		name := r["Name"]
+		version := r["Version"]
+		if name == "" || version == "" {
 			return
 		}
-		if _, err := strconv.Atoi(fields[0]); err != nil {
+		switch {
+		case strings.Contains(name, "iDRAC") || strings.Contains(name, "BMC") || strings.Contains(name, "Baseboard Management Controller"):
+			addText("dell.hardware.inventory", "", "bmc_version", version, nil, descDellHWBmcVersion)
+		case strings.Contains(name, "Lifecycle Controller"):
+			addText("dell.hardware.inventory", "", "lifecycle_controller_version", version, nil, descDellHWLcVersion)
+		}
+		firmwareName := replace(name)
+		ts := labels{"{#FIRMWARENAME}": firmwareName}
+		addText("dell.hardware.firmware", firmwareName, "version", version, ts, descDellHWFirmware)
+	}, "system", "version")
+	return firstError(errBios, errVersions)
+}
+
+// omreportSummary reports the system identification. With --host-inventory, the
+// values are also sent under the names of Zabbix host inventory fields, so a
+// template can have each item populate the inventory field of the same name.
+func omreportSummary(om omReporter) error {
+	inventory := func(metricType string, value string, desc string, field string) {
+		if value == "" {
 			return
 		}
-		ts := labels{"name": replace(fields[2])}
-		add("dell.hardware.chassis.volts", "", "status", severity(fields[1]), ts, descDellHWVolt)
-		if i, err := extract(fields[3], "V"); err == nil {
-			add("dell.hardware.chassis.volts", "", "reading", i, ts, descDellHWVoltReadings)
+		addText("dell.hardware.inventory", "", metricType, value, nil, desc)
+		if zabbixHostInventory && field != "" {
+			addText("dell.hardware.host_inventory", "", field, value, nil, descDellHWHostInventory)
 		}
-	}, "chassis", "volts")
-	return nil
+	}
+	errChassis := om.Report("ChassisInfo", func(r omRecord) {
+		inventory("model", r["ChassisModel"], descDellHWModel, "model")
+		inventory("service_tag", r["ServiceTag"], descDellHWServiceTag, "serialno_a")
+		inventory("express_service_code", r["ExpressServiceCode"], descDellHWExpressCode, "serialno_b")
+		inventory("asset_tag", r["AssetTag"], descDellHWAssetTag, "asset_tag")
+		inventory("system_revision", r["SystemRevision"], descDellHWRevision, "")
+	}, "chassis", "info")
+	errSystem := om.Report("OMA", func(r omRecord) {
+		inventory("omsa_version", r["SystemManagement.Version"], descDellHWOmsaVersion, "")
+		inventory("os_name", r["OperatingSystem.Name"], descDellHWOsName, "os_short")
+		inventory("os_version", r["OperatingSystem.Version"], descDellHWOsVersion, "os")
+	}, "system", "summary")
+	return firstError(errChassis, errSystem)
 }
@@ -1,9 +1,13 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func showCache() {
@@ -12,6 +16,15 @@ func showCache() {
 	}
 }
 
+// checkItems checks that the cache holds each key of expected with its value.
+func checkItems(t *testing.T, expected map[string]string) {
+	for key, value := range expected {
+		if cache.metrics[key].Value != value {
+			t.Error("Expected ", key, " to be ", value, ", got ", cache.metrics[key].Value)
+		}
+	}
+}
+
 type testOmReport struct{}
 
 func newTestOmReport() *testOmReport {
@@ -21,38 +34,121 @@ func newTestOmReport() *testOmReport {
 //
 // Mock omreport command
 
-func (o *testOmReport) Report(f func([]string), args ...string) {
-	// Fake "omreport chassis" splitted string return
+func (o *testOmReport) Report(object string, f func(omRecord), args ...string) error {
+	// Fake "omreport chassis" parsed records
 	if reflect.DeepEqual(args, []string{"chassis"}) {
-		sp := []string{"Ok", "testChassisName"}
-		f(sp)
+		f(omRecord{"ObjName": "testChassisName", "ObjStatus": "2"})
+		f(omRecord{"ObjName": "Hardware Log", "ObjStatus": "4"})
 	}
-	// Fake "omreport system" splitted string return
+	// Fake "omreport system" parsed record
 	if reflect.DeepEqual(args, []string{"system"}) {
-		sp := []string{"Ok", "testSystemName"}
-		f(sp)
+		f(omRecord{"ObjName": "testSystemName", "ObjStatus": "2"})
 	}
-	// Fake "omreport storage enclosure" splitted string return
+	// Fake "omreport storage enclosure" parsed record
 	if reflect.DeepEqual(args, []string{"storage", "enclosure"}) {
-		sp := []string{"0:1", "Ok", "blah"}
-		f(sp)
+		f(omRecord{"Channel": "0", "EnclosureID": "1", "ObjStatus": "2"})
 	}
-	// Fake "omreport storage vdisk" splitted string return
+	// Fake "omreport storage vdisk" parsed record
 	if reflect.DeepEqual(args, []string{"storage", "vdisk"}) {
-		sp := []string{"0:1", "Ok", "blah"}
-		f(sp)
+		f(omRecord{"DeviceID": "0_1", "ObjStatus": "2", "ObjState": "32", "Layout": "64",
+			"WritePolicy": "2", "Progress": "17"})
 	}
-	// Fake "omreport chassis pwrsupplies" splitted string return
+	// Fake "omreport chassis pwrsupplies" parsed record
 	if reflect.DeepEqual(args, []string{"chassis", "pwrsupplies"}) {
-		sp := []string{"0:1", "Ok", "blah", "bloh", "42 W", "4242 W"}
-		f(sp)
+		f(omRecord{"index": "0_1", "ObjStatus": "2", "InputRatedWatts": "42", "OutputWatts": "4242"})
+	}
+	// Fake "omreport storage controller" parsed record
+	if reflect.DeepEqual(args, []string{"storage", "controller"}) {
+		f(omRecord{"ControllerNum": "0", "ObjStatus": "2", "FirmwareVer": "21.3.0-0009",
+			"DriverVersion": "06.805.06.00-rh1", "MinRequiredFirmwareVersion": "21.3.2-0005",
+			"MinRequiredDriverVersion": "Not Applicable", "RebuildRate": "30"})
+	}
+	// Fake "omreport storage battery" parsed record
+	if reflect.DeepEqual(args, []string{"storage", "battery"}) {
+		f(omRecord{"DeviceID": "0", "ObjStatus": "3", "ObjState": "128", "LearnMode": "1", "NextLearnTime": "778"})
+	}
+	// Fake "omreport storage pdisk controller=0" parsed record
+	if reflect.DeepEqual(args, []string{"storage", "pdisk", "controller=0"}) {
+		f(omRecord{"Channel": "0", "EnclosureID": "1", "TargetID": "3", "ObjStatus": "4", "ObjState": "1024",
+			"FailurePredicted": "true", "Progress": "42", "BusProtocol": "8", "MediaType": "2",
+			"Length": "599550590976", "RemainingRatedWriteEndurance": "255"})
+	}
+	// Fake "omreport chassis nics" parsed records, for interfaces and teams
+	if reflect.DeepEqual(args, []string{"chassis", "nics"}) && object == "NIC" {
+		f(omRecord{"InterfaceName": "em1", "ObjStatus": "2", "ConnectionStatus": "1", "LinkSpeed": "10000"})
+		f(omRecord{"InterfaceName": "em2", "ObjStatus": "2", "ConnectionStatus": "2"})
+	}
+	if reflect.DeepEqual(args, []string{"chassis", "nics"}) && object == "TeamInterface" {
+		f(omRecord{"InterfaceName": "bond0", "ObjStatus": "3", "ConnectionStatus": "1", "LinkSpeed": "10000"})
+	}
+	// Fake "omreport chassis bios" parsed record
+	if reflect.DeepEqual(args, []string{"chassis", "bios"}) {
+		f(omRecord{"Manufacturer": "Dell Inc.", "Version": "2.4.3", "ReleaseDate": "01/17/2017"})
+	}
+	// Fake "omreport system version" parsed records
+	if reflect.DeepEqual(args, []string{"system", "version"}) {
+		f(omRecord{"Name": "iDRAC8", "Version": "2.41.40.40 (Build 07)"})
+		f(omRecord{"Name": "Lifecycle Controller 2", "Version": "2.41.40.40"})
+		f(omRecord{"Name": "PERC H730P Mini", "Version": "25.5.0.0018"})
+	}
+	// Fake "omreport chassis info" parsed record
+	if reflect.DeepEqual(args, []string{"chassis", "info"}) {
+		f(omRecord{"ChassisModel": "PowerEdge R630", "ServiceTag": "ABC1234", "ExpressServiceCode": "22164320212"})
+	}
+	// Fake "omreport system summary" parsed record of the whole document
+	if reflect.DeepEqual(args, []string{"system", "summary"}) {
+		f(omRecord{"SystemManagement.Version": "8.4.0", "OperatingSystem.Name": "Linux"})
+	}
+	// Fake "omreport chassis temps" parsed record
+	if reflect.DeepEqual(args, []string{"chassis", "temps"}) {
+		f(omRecord{"ProbeLocation": "System Board Inlet Temp", "ProbeStatus": "2", "ProbeReading": "250",
+			"ProbeThresholds.UCThreshold": "470", "ProbeThresholds.UNCThreshold": "420",
+			"ProbeThresholds.LNCThreshold": "30", "ProbeThresholds.LCThreshold": "-70",
+			"ProbeThresholds.UNRThreshold": "-2147483648"})
+	}
+	// Fake "omreport chassis volts" parsed records, the second one of a discrete probe
+	if reflect.DeepEqual(args, []string{"chassis", "volts"}) {
+		f(omRecord{"ProbeLocation": "PS1 Voltage 1", "ProbeStatus": "2", "ProbeReading": "230000"})
+		f(omRecord{"ProbeLocation": "CPU1 VCORE PG", "ProbeStatus": "4"})
+	}
+	// Fake "omreport chassis processors" parsed records, the second CPU with disabled cores
+	if reflect.DeepEqual(args, []string{"chassis", "processors"}) {
+		f(omRecord{"ConnectorName": "CPU1", "ObjStatus": "2", "Brand": "Intel(R) Xeon(R) CPU E5-2630 v3 @ 2.40GHz",
+			"CurSpeed": "2400", "MaxSpeed": "4000", "CoreCount": "8", "State": "1"})
+		f(omRecord{"ConnectorName": "CPU2", "ObjStatus": "2", "Brand": "Intel(R) Xeon(R) CPU E5-2630 v3 @ 2.40GHz",
+			"CurSpeed": "2400", "MaxSpeed": "4000", "CoreCount": "6", "State": "1"})
+	}
+	// Fake "omreport chassis memory" parsed records, for DIMMs and the whole document
+	if reflect.DeepEqual(args, []string{"chassis", "memory"}) && object == "MemDevObj" {
+		f(omRecord{"DeviceLocator": "A1", "ObjStatus": "2", "Size": "16384", "Type": "26", "Speed": "2133"})
+	}
+	if reflect.DeepEqual(args, []string{"chassis", "memory"}) && object == "OMA" {
+		f(omRecord{"MemoryInfo.TotalInstalledCapacity": "65536", "MemoryInfo.TotalAvailableCapacity": "48882",
+			"MemoryInfo.RedundancyConfiguration": "Optimizer Mode"})
+	}
+	// Fake "omreport chassis fans" parsed record
+	if reflect.DeepEqual(args, []string{"chassis", "fans"}) {
+		f(omRecord{"ProbeLocation": "System Board Fan1A", "ProbeStatus": "2", "ProbeReading": "4920",
+			"ProbeThresholds.LNCThreshold": "-2147483648", "ProbeThresholds.LCThreshold": "600"})
+	}
+	// Fake "omreport chassis pwrmonitoring" parsed records
+	if reflect.DeepEqual(args, []string{"chassis", "pwrmonitoring"}) && object == "CurrentProbe" {
+		f(omRecord{"ProbeLocation": "blah", "ProbeStatus": "2"})
+		f(omRecord{"ProbeLocation": "System Board Pwr Consumption", "ProbeStatus": "2", "ProbeReading": "126",
+			"ProbeThresholds.UNCThreshold": "588", "ProbeThresholds.UCThreshold": "644"})
 	}
-	// Fake "omreport chassis pwrmonitoring splitted string return
-	if reflect.DeepEqual(args, []string{"chassis", "pwrmonitoring"}) {
-		sp := []string{"0:1", "Ok", "blah"}
-		f(sp)
+	if reflect.DeepEqual(args, []string{"chassis", "pwrmonitoring"}) && object == "PowerConsumptionData" {
+		f(omRecord{"CumulativeEnergy": "1234567", "CumulativeEnergyStartTime": "1420070400",
+			"PeakPower": "310", "PeakAmperage": "14", "AvgPowerLastHour": "130"})
 	}
+	return nil
+}
 
+func (o *testOmReport) ReportObjects(fs map[string]func(omRecord), args ...string) error {
+	for object, f := range fs {
+		o.Report(object, f, args...)
+	}
+	return nil
 }
 
 //
@@ -61,24 +157,66 @@ func (o *testOmReport) Report(f func([]string), args ...string) {
 func TestOmReportChassis(t *testing.T) {
 	to := newTestOmReport()
 	omreportChassis(to)
-	returnedLabel := cache.metrics["dell.hardware.chassis[status]"].Labels["component"]
+	returnedLabel := cache.metrics["dell.hardware.chassis[testChassisName,status]"].Labels["{#CHASSISCOMPONENT}"]
 	if returnedLabel != "testChassisName" {
 		t.Error("Expected testChassisName, got ", returnedLabel)
 	}
-	value := cache.metrics["dell.hardware.chassis[status]"].Value
+	value := cache.metrics["dell.hardware.chassis[testChassisName,status]"].Value
 	if value != "0" {
 		t.Error("Expected return value 0, got ", value)
 	}
 }
 
+func TestOmReportChassisComponents(t *testing.T) {
+	resetCache()
+	to := newTestOmReport()
+	omreportChassis(to)
+	reportCounts()
+	reportStatuses()
+	returnedValue := cache.metrics["dell.hardware.chassis[Hardware_Log,status]"].Value
+	if returnedValue != "2" {
+		t.Error("Expected return value 2, got ", returnedValue)
+	}
+	returnedValue = cache.metrics["dell.hardware.chassis[number]"].Value
+	if returnedValue != "2" {
+		t.Error("Expected return value 2, got ", returnedValue)
+	}
+	returnedValue = cache.metrics["dell.hardware.chassis[status_sum]"].Value
+	if returnedValue != "2" {
+		t.Error("Expected return value 2, got ", returnedValue)
+	}
+	resetCache()
+}
+
+func TestReportStatusesWorst(t *testing.T) {
+	resetCache()
+	ts := labels{}
+	add("dell.hardware.fan", "Fan1", "status", "1", ts, descDellHWFan)
+	add("dell.hardware.fan", "Fan2", "status", "1", ts, descDellHWFan)
+	add("dell.hardware.memory", "A1", "status", "0", ts, descDellHWMemory)
+	add("dell.hardware.memory", "A2", "status", "-1", ts, descDellHWMemory)
+	add("dell.hardware.processors", "CPU1", "status", "2", ts, descDellHWCPU)
+	reportStatuses()
+	expected := map[string]string{
+		"dell.hardware.fan[status_sum]":        "2",
+		"dell.hardware.fan[status_max]":        "1",
+		"dell.hardware.memory[status_sum]":     "0",
+		"dell.hardware.memory[status_max]":     "0",
+		"dell.hardware.processors[status_max]": "2",
+		"dell.hardware[health]":                "2",
+	}
+	checkItems(t, expected)
+	resetCache()
+}
+
 func TestOmReportSystem(t *testing.T) {
 	to := newTestOmReport()
 	omreportSystem(to)
-	returnedLabel := cache.metrics["dell.hardware.system[status]"].Labels["component"]
+	returnedLabel := cache.metrics["dell.hardware.system[testSystemName,status]"].Labels["{#SYSTEMCOMPONENT}"]
 	if returnedLabel != "testSystemName" {
 		t.Error("Expected testSystemName, got ", returnedLabel)
 	}
-	returnedValue := cache.metrics["dell.hardware.system[status]"].Value
+	returnedValue := cache.metrics["dell.hardware.system[testSystemName,status]"].Value
 	if returnedValue != "0" {
 		t.Error("Expected return value 0, got ", returnedValue)
 	}
@@ -87,11 +225,11 @@ func TestOmReportSystem(t *testing.T) {
 func TestOmreportStorageEnclosure(t *testing.T) {
 	to := newTestOmReport()
 	omreportStorageEnclosure(to)
-	returnedLabel := cache.metrics["dell.hardware.storage.enclosure[status]"].Labels["id"]
+	returnedLabel := cache.metrics["dell.hardware.storage.enclosure[0_1,status]"].Labels["{#ENCLOSURESLOT}"]
 	if returnedLabel != "0_1" {
 		t.Error("Expected 0_1, got ", returnedLabel)
 	}
-	returnedValue := cache.metrics["dell.hardware.storage.enclosure[status]"].Value
+	returnedValue := cache.metrics["dell.hardware.storage.enclosure[0_1,status]"].Value
 	if returnedValue != "0" {
 		t.Error("Expected return value 0, got ", returnedValue)
 	}
@@ -99,6 +237,7 @@ func TestOmreportStorageEnclosure(t *testing.T) {
 }
 
 func TestOmreportStorageVdisk(t *testing.T) {
+	resetCache()
 	to := newTestOmReport()
 	omreportStorageVdisk(to)
 	returnedLabel := cache.metrics["dell.hardware.raid.logicaldrive[0_1,status]"].Labels["{#LOGICALDRIVESLOT}"]
@@ -109,6 +248,13 @@ func TestOmreportStorageVdisk(t *testing.T) {
 	if returnedValue != "0" {
 		t.Error("Expected return value 0, got ", returnedValue)
 	}
+	expected := map[string]string{
+		"dell.hardware.raid.logicaldrive[0_1,state]":        "Degraded",
+		"dell.hardware.raid.logicaldrive[0_1,layout]":       "RAID-5",
+		"dell.hardware.raid.logicaldrive[0_1,write_policy]": "Write Through",
+		"dell.hardware.raid.logicaldrive[0_1,progress]":     "17",
+	}
+	checkItems(t, expected)
 
 }
 
@@ -132,3 +278,442 @@ func TestOmreportPs(t *testing.T) {
 		t.Error("Expected return value 4242, got ", returnedoWattsValue)
 	}
 }
+
+func TestOmreportPsAmpsSysboardPwr(t *testing.T) {
+	resetCache()
+	to := newTestOmReport()
+	omreportPsAmpsSysboardPwr(to)
+	expected := map[string]string{
+		"dell.hardware.chassis.power[reading]":      "126",
+		"dell.hardware.chassis.power.warn[level]":   "588",
+		"dell.hardware.chassis.power.fail[level]":   "644",
+		"dell.hardware.chassis.power[energy]":       "1234.567",
+		"dell.hardware.chassis.power[energy_start]": "1420070400",
+		"dell.hardware.chassis.power[peak]":         "310",
+		"dell.hardware.chassis.power[peak_amps]":    "1.4",
+		"dell.hardware.chassis.power[avg_hour]":     "130",
+	}
+	checkItems(t, expected)
+}
+
+func TestOmreportStoragePdisk(t *testing.T) {
+	resetCache()
+	to := newTestOmReport()
+	omreportStoragePdisk(to, "0")
+	expected := map[string]string{
+		"dell.hardware.raid.physicaldrive[0_1_3,status]":            "2",
+		"dell.hardware.raid.physicaldrive[0_1_3,state]":             "Rebuilding",
+		"dell.hardware.raid.physicaldrive[0_1_3,failure_predicted]": "1",
+		"dell.hardware.raid.physicaldrive[0_1_3,progress]":          "42",
+		"dell.hardware.raid.physicaldrive[0_1_3,bus_protocol]":      "SAS",
+		"dell.hardware.raid.physicaldrive[0_1_3,media]":             "SSD",
+		"dell.hardware.raid.physicaldrive[0_1_3,capacity]":          "599550590976",
+	}
+	checkItems(t, expected)
+	if _, ok := cache.metrics["dell.hardware.raid.physicaldrive[0_1_3,status_text]"]; ok {
+		t.Error("Expected no status_text item without --status-text")
+	}
+	defer func(b bool) { statusText = b }(statusText)
+	statusText = true
+	omreportStoragePdisk(to, "0")
+	returnedText := cache.metrics["dell.hardware.raid.physicaldrive[0_1_3,status_text]"].Value
+	if returnedText != "Critical (Rebuilding)" {
+		t.Error("Expected Critical (Rebuilding), got ", returnedText)
+	}
+	if _, ok := cache.metrics["dell.hardware.raid.physicaldrive[0_1_3,write_endurance]"]; ok {
+		t.Error("Expected no write endurance item for a Not Applicable value")
+	}
+	returnedLabel := cache.metrics["dell.hardware.raid.physicaldrive[0_1_3,state]"].Labels["{#CONTROLLERSLOT}"]
+	if returnedLabel != "0" {
+		t.Error("Expected 0, got ", returnedLabel)
+	}
+}
+
+func TestOmreportStorageController(t *testing.T) {
+	resetCache()
+	to := newTestOmReport()
+	omreportStorageController(to)
+	expected := map[string]string{
+		"dell.hardware.raid.controller[0,status]":                        "0",
+		"dell.hardware.raid.controller[0,firmware_version]":              "21.3.0-0009",
+		"dell.hardware.raid.controller[0,rebuild_rate]":                  "30",
+		"dell.hardware.raid.controller[0,outdated]":                      "1",
+		"dell.hardware.raid.controller[0,min_required_firmware_version]": "21.3.2-0005",
+	}
+	checkItems(t, expected)
+	if _, ok := cache.metrics["dell.hardware.raid.controller[0,min_required_driver_version]"]; ok {
+		t.Error("Expected no minimum driver version when it is Not Applicable")
+	}
+	if _, ok := cache.metrics["dell.hardware.raid.physicaldrive[0_1_3,status]"]; !ok {
+		t.Error("Expected physical disks of controller 0 to be collected")
+	}
+}
+
+func TestOmreportStorageBattery(t *testing.T) {
+	resetCache()
+	to := newTestOmReport()
+	omreportStorageBattery(to)
+	expected := map[string]string{
+		"dell.hardware.storage.battery[0,state]":           "Learning",
+		"dell.hardware.storage.battery[0,learning]":        "1",
+		"dell.hardware.storage.battery[0,learn_mode]":      "Auto",
+		"dell.hardware.storage.battery[0,next_learn_time]": "778",
+	}
+	checkItems(t, expected)
+	returnedLabel := cache.metrics["dell.hardware.storage.battery[0,status]"].Labels["{#BATTERYSLOT}"]
+	if returnedLabel != "0" {
+		t.Error("Expected 0, got ", returnedLabel)
+	}
+}
+
+func TestOmreportNics(t *testing.T) {
+	resetCache()
+	to := newTestOmReport()
+	omreportNics(to)
+	expected := map[string]string{
+		"dell.hardware.nic[em1,link]":    "1",
+		"dell.hardware.nic[em1,speed]":   "10000",
+		"dell.hardware.nic[em2,link]":    "0",
+		"dell.hardware.nic[bond0,link]":  "1",
+		"dell.hardware.nic[bond0,speed]": "10000",
+	}
+	checkItems(t, expected)
+	returnedLabel := cache.metrics["dell.hardware.nic[bond0,status]"].Labels["{#NICNAME}"]
+	if returnedLabel != "bond0" {
+		t.Error("Expected bond0, got ", returnedLabel)
+	}
+}
+
+func TestOmreportFirmware(t *testing.T) {
+	resetCache()
+	to := newTestOmReport()
+	omreportFirmware(to)
+	expected := map[string]string{
+		"dell.hardware.inventory[bios_version]":                 "2.4.3",
+		"dell.hardware.inventory[bios_release_date]":            "01/17/2017",
+		"dell.hardware.inventory[bmc_version]":                  "2.41.40.40 (Build 07)",
+		"dell.hardware.inventory[lifecycle_controller_version]": "2.41.40.40",
+		"dell.hardware.firmware[PERC_H730P_Mini,version]":       "25.5.0.0018",
+	}
+	checkItems(t, expected)
+	for key := range expected {
+		if !cache.metrics[key].Text {
+			t.Error("Expected ", key, " to be a text item")
+		}
+	}
+	if _, ok := metricCounts["dell.hardware.firmware"]; ok {
+		t.Error("Expected text items not to be counted as components")
+	}
+}
+
+func TestOmreportSummary(t *testing.T) {
+	resetCache()
+	to := newTestOmReport()
+	defer func(b bool) { zabbixHostInventory = b }(zabbixHostInventory)
+	zabbixHostInventory = true
+	omreportSummary(to)
+	expected := map[string]string{
+		"dell.hardware.inventory[model]":                "PowerEdge R630",
+		"dell.hardware.inventory[service_tag]":          "ABC1234",
+		"dell.hardware.inventory[express_service_code]": "22164320212",
+		"dell.hardware.inventory[omsa_version]":         "8.4.0",
+		"dell.hardware.inventory[os_name]":              "Linux",
+		"dell.hardware.host_inventory[serialno_a]":      "ABC1234",
+	}
+	checkItems(t, expected)
+	if _, ok := cache.metrics["dell.hardware.inventory[asset_tag]"]; ok {
+		t.Error("Expected no asset tag item for an empty value")
+	}
+}
+
+func TestOmreportTempsThresholds(t *testing.T) {
+	resetCache()
+	to := newTestOmReport()
+	omreportTemps(to)
+	expected := map[string]string{
+		"dell.hardware.chassis.temps[System_Board_Inlet_Temp,warn_max]": "42",
+		"dell.hardware.chassis.temps[System_Board_Inlet_Temp,fail_max]": "47",
+		"dell.hardware.chassis.temps[System_Board_Inlet_Temp,warn_min]": "3",
+		"dell.hardware.chassis.temps[System_Board_Inlet_Temp,fail_min]": "-7",
+	}
+	checkItems(t, expected)
+}
+
+func TestOmreportTemps(t *testing.T) {
+	resetCache()
+	to := newTestOmReport()
+	omreportTemps(to)
+	returnedLabel := cache.metrics["dell.hardware.chassis.temps[System_Board_Inlet_Temp,status]"].Labels["{#TEMPNAME}"]
+	if returnedLabel != "System_Board_Inlet_Temp" {
+		t.Error("Expected System_Board_Inlet_Temp, got ", returnedLabel)
+	}
+	returnedValue := cache.metrics["dell.hardware.chassis.temps[System_Board_Inlet_Temp,reading]"].Value
+	if returnedValue != "25" {
+		t.Error("Expected return value 25, got ", returnedValue)
+	}
+}
+
+func TestOmreportVolts(t *testing.T) {
+	resetCache()
+	to := newTestOmReport()
+	omreportVolts(to)
+	returnedLabel := cache.metrics["dell.hardware.chassis.volts[PS1_Voltage_1,status]"].Labels["{#VOLTNAME}"]
+	if returnedLabel != "PS1_Voltage_1" {
+		t.Error("Expected PS1_Voltage_1, got ", returnedLabel)
+	}
+	returnedValue := cache.metrics["dell.hardware.chassis.volts[PS1_Voltage_1,reading]"].Value
+	if returnedValue != "230" {
+		t.Error("Expected return value 230, got ", returnedValue)
+	}
+	returnedValue = cache.metrics["dell.hardware.chassis.volts[CPU1_VCORE_PG,status]"].Value
+	if returnedValue != "2" {
+		t.Error("Expected return value 2, got ", returnedValue)
+	}
+}
+
+func TestOmreportProcessors(t *testing.T) {
+	resetCache()
+	to := newTestOmReport()
+	omreportProcessors(to)
+	expected := map[string]string{
+		"dell.hardware.processors[CPU1,status]":   "0",
+		"dell.hardware.processors[CPU1,brand]":    "Intel(R) Xeon(R) CPU E5-2630 v3 @ 2.40GHz",
+		"dell.hardware.processors[CPU1,state]":    "Present",
+		"dell.hardware.processors[CPU1,speed]":    "2400",
+		"dell.hardware.processors[CPU1,cores]":    "8",
+		"dell.hardware.processors[CPU1,mismatch]": "0",
+		"dell.hardware.processors[CPU2,cores]":    "6",
+		"dell.hardware.processors[CPU2,mismatch]": "1",
+	}
+	checkItems(t, expected)
+}
+
+func TestOmreportMemory(t *testing.T) {
+	resetCache()
+	to := newTestOmReport()
+	omreportMemory(to)
+	expected := map[string]string{
+		"dell.hardware.memory[A1,status]":   "0",
+		"dell.hardware.memory[A1,size]":     "16384",
+		"dell.hardware.memory[A1,type]":     "DDR4",
+		"dell.hardware.memory[A1,speed]":    "2133",
+		"dell.hardware.memory[installed]":   "65536",
+		"dell.hardware.memory[available]":   "48882",
+		"dell.hardware.memory[unavailable]": "16654",
+		"dell.hardware.memory[redundancy]":  "Optimizer Mode",
+	}
+	checkItems(t, expected)
+}
+
+func TestOmreportFansThresholds(t *testing.T) {
+	resetCache()
+	to := newTestOmReport()
+	omreportFans(to)
+	returnedValue := cache.metrics["dell.hardware.fan[System Board Fan1A,fail_min]"].Value
+	if returnedValue != "600" {
+		t.Error("Expected return value 600, got ", returnedValue)
+	}
+	if _, ok := cache.metrics["dell.hardware.fan[System Board Fan1A,warn_min]"]; ok {
+		t.Error("Expected no item for an unset threshold")
+	}
+}
+
+func TestSeverity(t *testing.T) {
+	expected := map[string]string{
+		"Ok":              "0",
+		"Non-Critical":    "1",
+		"Critical":        "2",
+		"Non-Recoverable": "3",
+		"Unknown":         "-1",
+		"Not Applicable":  "-2",
+		"Something else":  "-1",
+	}
+	for status, code := range expected {
+		if severity(status) != code {
+			t.Error("Expected ", status, " to be ", code, ", got ", severity(status))
+		}
+	}
+	if err := parseSeverityMap("Unknown=2, Non-Critical=2"); err != nil {
+		t.Fatal("Expected no error, got ", err)
+	}
+	if severity("Unknown") != "2" || severity("Something else") != "2" {
+		t.Error("Expected Unknown statuses to be 2, got ", severity("Unknown"))
+	}
+	if err := parseSeverityMap("Unknown"); err == nil {
+		t.Error("Expected an error for a mapping without code")
+	}
+	if err := parseSeverityMap("non-critical=3"); err != nil || severity("Non-Critical") != "3" {
+		t.Error("Expected statuses to match case-insensitively, got ", severity("Non-Critical"), err)
+	}
+	if err := parseSeverityMap("Okay=5"); err == nil {
+		t.Error("Expected an error for an unknown status")
+	}
+	parseSeverityMap("Unknown=-1,Non-Critical=1")
+}
+
+func TestCompareVersions(t *testing.T) {
+	if compareVersions("21.3.0-0009", "21.3.2-0005") != -1 {
+		t.Error("Expected 21.3.0-0009 to be older than 21.3.2-0005")
+	}
+	if compareVersions("2.10", "2.9") != 1 {
+		t.Error("Expected 2.10 to be newer than 2.9")
+	}
+	if compareVersions("1.0", "1.0.0") != 0 {
+		t.Error("Expected 1.0 to equal 1.0.0")
+	}
+	if belowMinimum("", "21.3.2-0005") {
+		t.Error("Expected a missing version not to be below the minimum")
+	}
+}
+
+func TestCollectFailingCollector(t *testing.T) {
+	testCollectors := map[string]collector{
+		"broken": collector{F: func(om omReporter) error { return errors.New("omreport not found") }},
+		"dummy":  collector{F: dummyReport},
+	}
+	defer func(c string) { enabledCollectors = c }(enabledCollectors)
+	enabledCollectors = "broken,dummy"
+	resetCache()
+	if err := collect(testCollectors); err == nil {
+		t.Error("Expected an error for the broken collector")
+	}
+	v := cache.metrics["dell.hardware.collector[broken,success]"].Value
+	if v != "0" {
+		t.Error("Expected 0, got ", v)
+	}
+	v = cache.metrics["dell.hardware.collector[dummy,success]"].Value
+	if v != "1" {
+		t.Error("Expected 1, got ", v)
+	}
+	if _, ok := cache.metrics["dummy[status]"]; !ok {
+		t.Error("Expected the dummy collector to run after the broken one")
+	}
+	if _, ok := cache.metrics["dell.hardware.collector[dummy,duration_ms]"]; !ok {
+		t.Error("Expected a duration_ms item for the dummy collector")
+	}
+}
+
+func TestCollectParallel(t *testing.T) {
+	testCollectors := map[string]collector{}
+	names := []string{}
+	for i := 0; i < 20; i++ {
+		name := fmt.Sprintf("fake%d", i)
+		testCollectors[name] = collector{F: func(om omReporter) error {
+			add("fake", name, "status", "1", labels{"{#FAKE}": name}, "Fake description")
+			addText("fake", name, "status_text", "Non-Critical", labels{"{#FAKE}": name}, "Fake description")
+			return nil
+		}}
+		names = append(names, name)
+	}
+	defer func(c string, p int) { enabledCollectors, collectParallelism = c, p }(enabledCollectors, collectParallelism)
+	enabledCollectors = strings.Join(names, ",")
+	collectParallelism = 4
+	resetCache()
+	if err := collect(testCollectors); err != nil {
+		t.Fatal("Expected no error, got ", err)
+	}
+	v := cache.metrics["fake[number]"].Value
+	if v != "20" {
+		t.Error("Expected 20, got ", v)
+	}
+	v = cache.metrics["fake[status_sum]"].Value
+	if v != "20" {
+		t.Error("Expected 20, got ", v)
+	}
+}
+
+func TestResolveCollectors(t *testing.T) {
+	names, err := resolveCollectors("storage_*,-storage_vdisk,fans", collectors)
+	expected := []string{"storage_battery", "storage_controller", "storage_enclosure", "fans"}
+	if err != nil || !reflect.DeepEqual(names, expected) {
+		t.Error("Expected ", expected, ", got ", names, err)
+	}
+	names, _ = resolveCollectors("-storage_vdisk,-volts", collectors)
+	if len(names) != len(strings.Split(defaultCollectors, ","))-2 {
+		t.Error("Expected the default collectors but storage_vdisk and volts, got ", names)
+	}
+	names, _ = resolveCollectors("all", collectors)
+	if len(names) != len(collectors)-1 {
+		t.Error("Expected every collector but dummy, got ", names)
+	}
+	names, _ = resolveCollectors("*", collectors)
+	if len(names) != len(collectors)-1 {
+		t.Error("Expected globs not to match dummy, got ", names)
+	}
+	names, _ = resolveCollectors("dummy", collectors)
+	if !reflect.DeepEqual(names, []string{"dummy"}) {
+		t.Error("Expected dummy when named, got ", names)
+	}
+	_, err = resolveCollectors("fans,memroy", collectors)
+	if err == nil || !strings.Contains(err.Error(), "memroy") || !strings.Contains(err.Error(), "memory") {
+		t.Error("Expected an error naming memroy and the valid collectors, got ", err)
+	}
+	if _, err = resolveCollectors("fans,-fans", collectors); err == nil {
+		t.Error("Expected an error when no collector is selected")
+	}
+}
+
+func TestOmReportDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), -time.Second)
+	defer cancel()
+	om := newOmReport(ctx, 0, nil)
+	called := false
+	err := om.Report("Fan", func(r omRecord) { called = true }, "chassis", "fans")
+	if err == nil || !strings.Contains(err.Error(), errDeadline.Error()) {
+		t.Error("Expected a deadline error, got ", err)
+	}
+	if called {
+		t.Error("Expected no record past the deadline")
+	}
+	// A call waits for a free slot, until the deadline.
+	slots := make(chan struct{}, 1)
+	slots <- struct{}{}
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = newOmReport(ctx, 0, slots).Report("Fan", func(r omRecord) { called = true }, "chassis", "fans")
+	if err == nil || !strings.Contains(err.Error(), errDeadline.Error()) {
+		t.Error("Expected a deadline error while all slots are taken, got ", err)
+	}
+	// The collector timeout only counts once a slot is held.
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		<-slots
+	}()
+	om = newOmReport(context.Background(), 50*time.Millisecond, slots)
+	err = om.Report("Fan", func(r omRecord) { called = true }, "chassis", "fans")
+	if err != nil && strings.Contains(err.Error(), errDeadline.Error()) {
+		t.Error("Expected no deadline error after waiting for a slot, got ", err)
+	}
+	if om.runTime() >= 100*time.Millisecond {
+		t.Error("Expected the wait for a slot not to count in the run time, got ", om.runTime())
+	}
+	// Calls of a collector share its timeout.
+	om = newOmReport(context.Background(), 50*time.Millisecond, nil)
+	om.addRunTime(50 * time.Millisecond)
+	err = om.Report("Fan", func(r omRecord) { called = true }, "chassis", "fans")
+	if err == nil || !strings.Contains(err.Error(), errDeadline.Error()) {
+		t.Error("Expected a deadline error once the collector timeout is spent, got ", err)
+	}
+}
+
+func TestParseCollectorTimeouts(t *testing.T) {
+	defer func(d time.Duration) {
+		defaultCollectorTimeout = d
+		collectorTimeouts = map[string]time.Duration{}
+	}(defaultCollectorTimeout)
+	if err := parseCollectorTimeouts("20s,storage_controller=2m", collectors); err != nil {
+		t.Fatal("Expected no error, got ", err)
+	}
+	if v := collectorTimeout("fans"); v != 20*time.Second {
+		t.Error("Expected 20s, got ", v)
+	}
+	if v := collectorTimeout("storage_controller"); v != 2*time.Minute {
+		t.Error("Expected 2m, got ", v)
+	}
+	if err := parseCollectorTimeouts("storage_controller=forever", collectors); err == nil {
+		t.Error("Expected an error for an invalid duration")
+	}
+	if err := parseCollectorTimeouts("storage_controllr=1m", collectors); err == nil || !strings.Contains(err.Error(), "storage_controllr") {
+		t.Error("Expected an error naming the unknown collector, got ", err)
+	}
+}
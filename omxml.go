@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// omRecord holds the fields of one object of omreport XML output, keyed by
+// element name. Attributes are keyed by their name, and nested elements are
+// flattened with a dot, e.g. "ProbeThresholds.UCThreshold".
+type omRecord map[string]string
+
+// omNode is a generic XML element, used to walk omreport output without
+// knowing its schema.
+type omNode struct {
+	XMLName  xml.Name
+	Attrs    []xml.Attr `xml:",any,attr"`
+	Text     string     `xml:",chardata"`
+	Children []omNode   `xml:",any"`
+}
+
+// omStatusNames maps the numeric object statuses of omreport XML output to
+// the words used by omreport text output.
+var omStatusNames = map[string]string{
+	"0": "Unknown",
+	"1": "Other",
+	"2": "Ok",
+	"3": "Non-Critical",
+	"4": "Critical",
+	"5": "Non-Recoverable",
+}
+
+// omStorageStates maps the numeric ObjState of storage objects to the words
+// used by omreport text output.
+var omStorageStates = map[string]string{
+	"1":       "Ready",
+	"2":       "Failed",
+	"4":       "Online",
+	"8":       "Offline",
+	"32":      "Degraded",
+	"64":      "Recovering",
+	"128":     "Removed",
+	"1024":    "Rebuilding",
+	"2048":    "No Media",
+	"4096":    "Formatting",
+	"16384":   "Diagnostics",
+	"262144":  "Resynching",
+	"524288":  "Background Initialization",
+	"8388608": "Foreign",
+}
+
+// parseOmXML reads omreport XML output from r and calls the function of fs
+// named after each element with its record, wherever it is in the document.
+// An element is not searched for objects of its own name.
+func parseOmXML(r io.Reader, fs map[string]func(omRecord)) error {
+	var root omNode
+	if err := xml.NewDecoder(r).Decode(&root); err != nil {
+		return err
+	}
+	root.walk(fs)
+	return nil
+}
+
+func (n omNode) walk(fs map[string]func(omRecord)) {
+	if f, ok := fs[n.XMLName.Local]; ok {
+		r := omRecord{}
+		n.flatten("", r)
+		f(r)
+		if len(fs) == 1 {
+			return
+		}
+		// Other objects may be nested in this one, such as the DIMMs of the
+		// OMA document.
+		others := make(map[string]func(omRecord), len(fs)-1)
+		for object, f := range fs {
+			if object != n.XMLName.Local {
+				others[object] = f
+			}
+		}
+		fs = others
+	}
+	for _, c := range n.Children {
+		c.walk(fs)
+	}
+}
+
+func (n omNode) flatten(prefix string, r omRecord) {
+	for _, a := range n.Attrs {
+		r[prefix+a.Name.Local] = a.Value
+	}
+	for _, c := range n.Children {
+		key := prefix + c.XMLName.Local
+		if text := clean(c.Text); text != "" {
+			r[key] = text
+		}
+		c.flatten(key+".", r)
+	}
+}
+
+// status returns the omreport status word for the numeric status in field.
+// Unknown codes are returned as is.
+func (r omRecord) status(field string) string {
+	return r.enum(field, omStatusNames)
+}
+
+// enum returns the name of the numeric code in field according to names.
+// Unknown codes are returned as is.
+func (r omRecord) enum(field string, names map[string]string) string {
+	if name, ok := names[r[field]]; ok {
+		return name
+	}
+	return r[field]
+}
+
+// flag returns "1" if field holds a true value, "0" if it holds a false one.
+func (r omRecord) flag(field string) (string, bool) {
+	switch strings.ToLower(r[field]) {
+	case "1", "true", "yes":
+		return "1", true
+	case "0", "false", "no":
+		return "0", true
+	}
+	return "", false
+}
+
+// percent returns the percentage in field, if it is between 0 and 100.
+// omreport uses out of range values such as 255 for "Not Applicable".
+func (r omRecord) percent(field string) (string, bool) {
+	v, err := strconv.Atoi(r[field])
+	if err != nil || v < 0 || v > 100 {
+		return "", false
+	}
+	return r[field], true
+}
+
+// omUnsetThreshold is the value omreport XML output gives to thresholds that
+// are not set on a probe.
+const omUnsetThreshold = "-2147483648"
+
+// threshold returns the probe threshold in field divided by divisor, if it is set.
+func (r omRecord) threshold(field string, divisor float64) (string, bool) {
+	if r[field] == omUnsetThreshold {
+		return "", false
+	}
+	return r.scaled(field, divisor)
+}
+
+// scaled returns the number in field divided by divisor, since omreport XML
+// output gives some readings in tenths or thousandths of their unit.
+func (r omRecord) scaled(field string, divisor float64) (string, bool) {
+	v, err := strconv.ParseFloat(r[field], 64)
+	if err != nil {
+		return "", false
+	}
+	return strconv.FormatFloat(v/divisor, 'f', -1, 64), true
+}
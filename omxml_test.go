@@ -0,0 +1,60 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const testTempsXML = `<?xml version="1.0" encoding="UTF-8"?>
+<OMA cli="true">
+<TemperatureProbeList poid="2" count="2">
+<TemperatureProbe oid="50331648" status="2" poid="2" pindex="0" index="0">
+<ProbeReading>370</ProbeReading>
+<ProbeThresholds>
+<UCThreshold>470</UCThreshold>
+<UNCThreshold>420</UNCThreshold>
+</ProbeThresholds>
+<ProbeStatus>2</ProbeStatus>
+<ProbeLocation>System Board Inlet Temp</ProbeLocation>
+</TemperatureProbe>
+<TemperatureProbe oid="50331649" status="4" poid="2" pindex="0" index="1">
+<ProbeReading>750</ProbeReading>
+<ProbeStatus>4</ProbeStatus>
+<ProbeLocation>System Board Exhaust Temp</ProbeLocation>
+</TemperatureProbe>
+</TemperatureProbeList>
+</OMA>
+`
+
+func TestParseOmXML(t *testing.T) {
+	records := []omRecord{}
+	roots := []omRecord{}
+	err := parseOmXML(strings.NewReader(testTempsXML), map[string]func(omRecord){
+		"TemperatureProbe": func(r omRecord) { records = append(records, r) },
+		"OMA":              func(r omRecord) { roots = append(roots, r) },
+	})
+	if err != nil {
+		t.Fatal("Expected no error, got ", err)
+	}
+	if len(records) != 2 {
+		t.Fatal("Expected 2 records, got ", len(records))
+	}
+	if len(roots) != 1 || roots[0]["cli"] != "true" {
+		t.Error("Expected the OMA record along with the nested probes, got ", roots)
+	}
+	if records[0]["ProbeLocation"] != "System Board Inlet Temp" {
+		t.Error("Expected System Board Inlet Temp, got ", records[0]["ProbeLocation"])
+	}
+	if records[0]["index"] != "0" {
+		t.Error("Expected attribute index 0, got ", records[0]["index"])
+	}
+	if records[0]["ProbeThresholds.UNCThreshold"] != "420" {
+		t.Error("Expected nested threshold 420, got ", records[0]["ProbeThresholds.UNCThreshold"])
+	}
+	if reading, _ := records[0].scaled("ProbeReading", 10); reading != "37" {
+		t.Error("Expected scaled reading 37, got ", reading)
+	}
+	if status := records[1].status("ProbeStatus"); status != "Critical" {
+		t.Error("Expected Critical, got ", status)
+	}
+}
@@ -1,27 +1,189 @@
 package main
 
 import (
+	"net/http"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	log "github.com/Sirupsen/logrus"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-func addToPrometheus(name string, value string, t prometheus.Labels, desc string) {
-	log.Debug("Adding metric : ", name, t, value)
-	d := prometheus.NewGauge(prometheus.GaugeOpts{
-		Namespace:   "dell",
-		Subsystem:   "hw",
-		Name:        name,
-		Help:        desc,
-		ConstLabels: t,
-	})
+// promExporter is a prometheus.Collector exposing the result of the last
+// collection run. With a zero interval, the collectors are run on each scrape.
+// Otherwise they are run in the background and scrapes do not wait for them.
+type promExporter struct {
+	// mu serialises collection runs, which share the metric cache.
+	mu       sync.Mutex
+	interval time.Duration
+
+	// familiesMu guards families, built from the metric cache after each run.
+	familiesMu sync.RWMutex
+	families   map[string]*promFamily
+}
+
+func newPromExporter(interval time.Duration) *promExporter {
+	return &promExporter{interval: interval}
+}
+
+// Describe sends nothing : metric families depend on the hardware found at
+// collection time, which makes promExporter an unchecked collector.
+func (e *promExporter) Describe(ch chan<- *prometheus.Desc) {}
+
+func (e *promExporter) Collect(ch chan<- prometheus.Metric) {
+	if e.interval == 0 {
+		e.refresh()
+	}
+	e.familiesMu.RLock()
+	families := e.families
+	e.familiesMu.RUnlock()
+	for _, family := range families {
+		family.vec.Collect(ch)
+	}
+}
+
+// refresh runs the enabled collectors into a clean cache, then replaces the
+// families served by Collect.
+func (e *promExporter) refresh() {
+	e.mu.Lock()
+	resetCache()
+	if err := collect(collectors); err != nil {
+		log.Error("Collect failed : ", err)
+	}
+	families := newPrometheusVecs(cache.metrics)
+	e.mu.Unlock()
+
+	e.familiesMu.Lock()
+	e.families = families
+	e.familiesMu.Unlock()
+}
+
+func (e *promExporter) run() {
+	e.refresh()
+	for range time.Tick(e.interval) {
+		e.refresh()
+	}
+}
+
+func serve(listenAddress string, metricsPath string, interval time.Duration) error {
+	exporter := newPromExporter(interval)
+	if interval > 0 {
+		go exporter.run()
+	}
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(exporter)
+
+	http.Handle(metricsPath, promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	log.Info("Listening on ", listenAddress, metricsPath)
+	return http.ListenAndServe(listenAddress, nil)
+}
+
+// promFamily is a GaugeVec along with the label names it was created with.
+type promFamily struct {
+	vec        *prometheus.GaugeVec
+	labelNames []string
+}
+
+// newPrometheusVecs builds one GaugeVec per item prefix and type, e.g.
+// dell.hardware.fan[...,speed] items all go to dell_hw_fan_speed. The label names
+// of a vector are "name" and every discovery macro found on its items.
+func newPrometheusVecs(metrics map[string]zabbixItem) map[string]*promFamily {
+	labelSets := make(map[string]map[string]bool)
+	helps := make(map[string]string)
+	for _, item := range metrics {
+		if item.Text {
+			continue
+		}
+		family := promMetricName(item.Prefix, item.Type)
+		if labelSets[family] == nil {
+			labelSets[family] = map[string]bool{"name": true}
+		}
+		for macro := range item.Labels {
+			labelSets[family][promLabelName(macro)] = true
+		}
+		helps[family] = item.Description
+	}
+
+	families := make(map[string]*promFamily)
+	for family, labelSet := range labelSets {
+		labelNames := []string{}
+		for name := range labelSet {
+			labelNames = append(labelNames, name)
+		}
+		sort.Strings(labelNames)
+		help := helps[family]
+		if help == "" {
+			help = family
+		}
+		vec := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "dell",
+			Subsystem: "hw",
+			Name:      family,
+			Help:      help,
+		}, labelNames)
+		families[family] = &promFamily{vec: vec, labelNames: labelNames}
+	}
+
+	for _, item := range metrics {
+		if !item.Text {
+			addToPrometheus(families, item)
+		}
+	}
+	return families
+}
+
+func addToPrometheus(families map[string]*promFamily, item zabbixItem) {
+	family := families[promMetricName(item.Prefix, item.Type)]
+	value, ok := item.Value.(string)
+	if !ok {
+		return
+	}
 	floatValue, err := strconv.ParseFloat(value, 64)
 	if err != nil {
-		log.Error("Could not parse value for metric ", name)
+		log.Debug("Could not parse value for metric ", item.Name)
 		return
 	}
-	d.Set(floatValue)
-	prometheus.MustRegister(d)
+	t := prometheus.Labels{}
+	// Items of the same family may not all carry the same discovery macros.
+	for _, labelName := range family.labelNames {
+		t[labelName] = ""
+	}
+	t["name"] = item.Component
+	for macro, labelValue := range item.Labels {
+		t[promLabelName(macro)] = labelValue
+	}
+	log.Debug("Adding metric : ", item.Name, t, value)
+	family.vec.With(t).Set(floatValue)
+}
+
+// promMetricName turns an item prefix and type into a Prometheus metric name,
+// without the dell_hw_ namespace : dell.hardware.raid.physicaldrive and status
+// give raid_physicaldrive_status.
+func promMetricName(prefix string, metricType string) string {
+	name := strings.TrimPrefix(prefix, "dell.hardware")
+	name = strings.TrimPrefix(name, ".")
+	if name == "" {
+		return promLabelName(metricType)
+	}
+	return promLabelName(name + "_" + metricType)
+}
+
+// promLabelName turns a Zabbix discovery macro such as {#FANNAME} into a valid
+// Prometheus label name such as fanname.
+func promLabelName(s string) string {
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "{#"), "}")
+	s = strings.ToLower(s)
+	s = strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '_' {
+			return r
+		}
+		return '_'
+	}, s)
+	// Names starting with __ are reserved by Prometheus.
+	return strings.TrimLeft(s, "_")
 }
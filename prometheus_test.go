@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestPromMetricName(t *testing.T) {
+	name := promMetricName("dell.hardware.raid.physicaldrive", "status")
+	if name != "raid_physicaldrive_status" {
+		t.Error("Expected raid_physicaldrive_status, got ", name)
+	}
+	label := promLabelName("{#PHYSICALDRIVESLOT}")
+	if label != "physicaldriveslot" {
+		t.Error("Expected physicaldriveslot, got ", label)
+	}
+}
+
+func TestNewPrometheusVecs(t *testing.T) {
+	resetCache()
+	ts := labels{"{#FANNAME}": "Fan1"}
+	add("dell.hardware.fan", "Fan1", "speed", "4920", ts, descDellHWFanSpeed)
+	add("dell.hardware.fan", "Fan2", "speed", "4560", labels{}, descDellHWFanSpeed)
+	add("dell.hardware.fan", "", "number", "2", labels{}, "Number of components of type fan")
+
+	registry := prometheus.NewRegistry()
+	for _, family := range newPrometheusVecs(cache.metrics) {
+		registry.MustRegister(family.vec)
+	}
+	mfs, err := registry.Gather()
+	if err != nil {
+		t.Fatal("Gather failed : ", err)
+	}
+	if len(mfs) != 2 {
+		t.Fatal("Expected 2 metric families, got ", len(mfs))
+	}
+	for _, mf := range mfs {
+		if mf.GetName() == "dell_hw_fan_speed" && len(mf.GetMetric()) != 2 {
+			t.Error("Expected 2 fan speed metrics, got ", len(mf.GetMetric()))
+		}
+	}
+	resetCache()
+}
+
+func TestPromExporterCollect(t *testing.T) {
+	defer func(c string) { enabledCollectors = c }(enabledCollectors)
+	enabledCollectors = "dummy"
+	e := newPromExporter(time.Hour)
+	e.refresh()
+	// Scrapes get the last run, whatever happens to the cache meanwhile.
+	resetCache()
+	ch := make(chan prometheus.Metric, 100)
+	e.Collect(ch)
+	close(ch)
+	if len(ch) == 0 {
+		t.Error("Expected the metrics of the last run, got none")
+	}
+}
@@ -1,7 +1,15 @@
 package main
 
+import "sync"
+
 type metricStorage struct {
+	// mu guards metrics and the per-type counters, since collectors run
+	// concurrently.
+	mu      sync.Mutex
 	metrics map[string]zabbixItem
+	// eventLogPositions are the event log positions reached by the eventlog
+	// collector, saved only once its entries have been sent.
+	eventLogPositions map[string]eventLogPosition
 }
 
 func newMetricStorage() *metricStorage {
@@ -9,3 +17,19 @@ func newMetricStorage() *metricStorage {
 	ms.metrics = make(map[string]zabbixItem)
 	return ms
 }
+
+// set stores item under its name. It is safe for concurrent use.
+func (ms *metricStorage) set(item zabbixItem) {
+	ms.mu.Lock()
+	ms.metrics[item.Name] = item
+	ms.mu.Unlock()
+}
+
+// resetCache empties the metric cache and the per-type counters, so that a
+// long-running process starts every collection from a clean state.
+func resetCache() {
+	cache = newMetricStorage()
+	metricCounts = make(map[string]int)
+	metricStatuses = make(map[string]int)
+	metricMaxStatuses = make(map[string]int)
+}
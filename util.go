@@ -1,13 +1,15 @@
 package main
 
 import (
-	"bufio"
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 	"unicode"
@@ -20,7 +22,7 @@ var (
 	// ErrPath is returned by Command if the program is not in the PATH.
 	ErrPath = errors.New("program not in PATH")
 	// ErrTimeout is returned by Command if the program timed out.
-	ErrTimeout = errors.New("program killed after timeout")
+	ErrTimeout = errors.New("program interrupted after timeout")
 )
 
 // clean concatenates arguments with a space and removes extra whitespace.
@@ -30,22 +32,73 @@ func clean(ss ...string) string {
 	return strings.Join(fs, " ")
 }
 
-// extract tries to return a parsed number from s with given suffix. A space may
-// be present between number ond suffix.
-func extract(s, suffix string) (string, error) {
-	if !strings.HasSuffix(s, suffix) {
-		return "0", fmt.Errorf("extract: suffix not found")
-	}
-	s = s[:len(s)-len(suffix)]
-	return strings.TrimSpace(s), nil
+// severityLevels maps omreport status words to the status codes sent to Zabbix.
+// Codes below 0 are not health levels, and are left out of status sums.
+// It can be changed with --severity-map.
+var severityLevels = map[string]int{
+	"Ok":              0,
+	"Non-Critical":    1,
+	"Critical":        2,
+	"Non-Recoverable": 3,
+	"Unknown":         -1,
+	"Other":           -1,
+	"Not Applicable":  -2,
 }
 
-// severity returns 0 if s is not "Ok" or "Non-Critical", else 1.
+// severity returns the status code of the omreport status word s. Words missing
+// from severityLevels get the code of "Unknown".
 func severity(s string) string {
-	if s != "Ok" && s != "Non-Critical" {
-		return "1"
+	level, ok := severityLevels[s]
+	if !ok {
+		log.Debug("Unknown status ", s)
+		level = severityLevels["Unknown"]
+	}
+	return strconv.Itoa(level)
+}
+
+// parseSeverityMap overrides severityLevels with a comma-separated list of
+// status=code pairs, such as "Non-Critical=2,Unknown=3". Statuses are matched
+// case-insensitively, and must be one of severityLevels.
+func parseSeverityMap(s string) error {
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("invalid severity mapping %q, expected status=code", pair)
+		}
+		level, err := strconv.Atoi(strings.TrimSpace(kv[1]))
+		if err != nil {
+			return fmt.Errorf("invalid severity code in %q", pair)
+		}
+		status, ok := severityStatus(strings.TrimSpace(kv[0]))
+		if !ok {
+			return fmt.Errorf("unknown status %q in severity mapping, valid statuses are : %s", kv[0], strings.Join(severityStatuses(), ", "))
+		}
+		severityLevels[status] = level
 	}
-	return "0"
+	return nil
+}
+
+// severityStatus returns the key of severityLevels matching s case-insensitively.
+func severityStatus(s string) (string, bool) {
+	for status := range severityLevels {
+		if strings.EqualFold(status, s) {
+			return status, true
+		}
+	}
+	return "", false
+}
+
+// severityStatuses returns the sorted keys of severityLevels.
+func severityStatuses() []string {
+	statuses := make([]string, 0, len(severityLevels))
+	for status := range severityLevels {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+	return statuses
 }
 
 func replace(name string) string {
@@ -73,67 +126,107 @@ func Replace(s, replacement string) (string, error) {
 	return c, nil
 }
 
-// Command executes the named program with the given arguments. If it does not
-// exit within timeout, it is sent SIGINT (if supported by Go). After
-// another timeout, it is killed.
-func Command(timeout time.Duration, stdin io.Reader, name string, arg ...string) (io.Reader, error) {
+// commandKillDelay is the time left to a program to exit after it is sent
+// SIGINT, before it is killed.
+var commandKillDelay = 5 * time.Second
+
+// maxStderr is the size of the end of stderr kept in a commandError.
+const maxStderr = 512
+
+// commandError is returned by Command if the program exits with a non zero
+// status. It holds the exit code and the end of what the program wrote to stderr.
+type commandError struct {
+	ExitCode int
+	Stderr   string
+}
+
+func (e *commandError) Error() string {
+	if e.Stderr == "" {
+		return fmt.Sprintf("exit status %d", e.ExitCode)
+	}
+	return fmt.Sprintf("exit status %d : %s", e.ExitCode, e.Stderr)
+}
+
+// Command executes the named program with the given arguments. When ctx is
+// done, it is sent SIGINT (if supported by Go). If it has not exited
+// commandKillDelay later, it is killed. ErrTimeout is returned if ctx reached
+// its deadline, and a *commandError if the program exited with a non zero
+// status.
+func Command(ctx context.Context, stdin io.Reader, name string, arg ...string) (io.Reader, error) {
 	if _, err := exec.LookPath(name); err != nil {
 		return nil, ErrPath
 	}
 	log.Debug("executing command: ", name, arg)
-	c := exec.Command(name, arg...)
+	c := exec.CommandContext(ctx, name, arg...)
+	c.Cancel = func() error {
+		log.Error("Process taking too long. Interrupting: ", name, " ", strings.Join(arg, " "))
+		return c.Process.Signal(os.Interrupt)
+	}
+	// Past the delay, the process is killed and its output pipes are closed,
+	// in case a child process keeps them open.
+	c.WaitDelay = commandKillDelay
 	b := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
 	c.Stdout = b
+	c.Stderr = stderr
 	c.Stdin = stdin
-	if err := c.Start(); err != nil {
-		return nil, err
-	}
-	timedOut := false
-	intTimer := time.AfterFunc(timeout, func() {
-		log.Error("Process taking too long. Interrupting: ", name, strings.Join(arg, " "))
-		c.Process.Signal(os.Interrupt)
-		timedOut = true
-	})
-	killTimer := time.AfterFunc(timeout, func() {
-		log.Error("Process taking too long. Killing: ", name, strings.Join(arg, " "))
-		c.Process.Signal(os.Interrupt)
-		timedOut = true
-	})
-	err := c.Wait()
-	intTimer.Stop()
-	killTimer.Stop()
-	if timedOut {
+	err := c.Run()
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
 		return nil, ErrTimeout
+	} else if err != nil && ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		msg := clean(stderr.String())
+		if len(msg) > maxStderr {
+			msg = "..." + msg[len(msg)-maxStderr:]
+		}
+		return b, &commandError{ExitCode: exitErr.ExitCode(), Stderr: msg}
 	}
 	return b, err
 }
 
-// ReadCommand runs command name with args and calls line for each line from its
-// stdout. Command is interrupted (if supported by Go) after 10 seconds and
-// killed after 20 seconds.
-func readCommand(line func(string) error, name string, arg ...string) error {
-	return readCommandTimeout(time.Second*10, line, nil, name, arg...)
+// readCommandOutput runs command name with args and returns its whole stdout,
+// for output that cannot be read line by line. Command is interrupted (if
+// supported by Go) when ctx is done, and killed 5 seconds later.
+func readCommandOutput(ctx context.Context, name string, arg ...string) (io.Reader, error) {
+	return Command(ctx, nil, name, arg...)
 }
 
-// ReadCommandTimeout is the same as ReadCommand with a specifiable timeout.
-// It can also take a []byte as input (useful for chaining commands).
-func readCommandTimeout(timeout time.Duration, line func(string) error, stdin io.Reader, name string, arg ...string) error {
-	b, err := Command(timeout, stdin, name, arg...)
-	if err != nil {
-		return err
-	}
-	scanner := bufio.NewScanner(b)
-	for scanner.Scan() {
-		if err := line(scanner.Text()); err != nil {
+// firstError returns the first non nil error of errs.
+func firstError(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
 			return err
 		}
 	}
-	if err := scanner.Err(); err != nil {
-		log.Error(name, " : ", err)
-	}
 	return nil
 }
 
+// compareVersions compares the numeric parts of two version strings such as
+// 21.3.0-0009, and returns -1, 0 or 1 if a is older, equal to or newer than b.
+func compareVersions(a string, b string) int {
+	notDigit := func(r rune) bool { return !unicode.IsDigit(r) }
+	as := strings.FieldsFunc(a, notDigit)
+	bs := strings.FieldsFunc(b, notDigit)
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var av, bv int
+		if i < len(as) {
+			av, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			bv, _ = strconv.Atoi(bs[i])
+		}
+		if av < bv {
+			return -1
+		}
+		if av > bv {
+			return 1
+		}
+	}
+	return 0
+}
+
 func getComponentType(prefix string) string {
 	prefixElements := strings.Split(prefix, ".")
 	componentType := prefixElements[len(prefixElements)-1]
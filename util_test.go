@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func TestCommand(t *testing.T) {
+	b, err := Command(context.Background(), nil, "sh", "-c", "echo out; echo oops >&2; exit 3")
+	cErr, ok := err.(*commandError)
+	if !ok {
+		t.Fatal("Expected a *commandError, got ", err)
+	}
+	if cErr.ExitCode != 3 || cErr.Stderr != "oops" {
+		t.Error("Expected exit status 3 : oops, got ", cErr)
+	}
+	out, _ := ioutil.ReadAll(b)
+	if string(out) != "out\n" {
+		t.Error("Expected out, got ", string(out))
+	}
+
+	// A program ignoring SIGINT is killed after commandKillDelay.
+	defer func(d time.Duration) { commandKillDelay = d }(commandKillDelay)
+	commandKillDelay = 200 * time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = Command(ctx, nil, "sh", "-c", "trap '' INT; sleep 30")
+	if err != ErrTimeout {
+		t.Error("Expected ErrTimeout, got ", err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Error("Expected the program to be killed, it ran for ", d)
+	}
+}
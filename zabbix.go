@@ -2,9 +2,8 @@ package main
 
 import (
 	"encoding/json"
-	"fmt"
+	"errors"
 	"net"
-	"os"
 
 	zabbix "github.com/AlekSi/zabbix-sender"
 	log "github.com/Sirupsen/logrus"
@@ -15,6 +14,14 @@ type zabbixItem struct {
 	Labels      map[string]string
 	Value       interface{}
 	Description string
+	// Text is set on items holding text rather than a number.
+	Text bool
+
+	// Prefix, Component and Type are the parts Name was built from. They are
+	// kept so other exporters can rebuild the item with their own naming.
+	Prefix    string
+	Component string
+	Type      string
 }
 
 func newZabbixItem(name string, labels labels, value string, desc string) *zabbixItem {
@@ -27,7 +34,12 @@ func newZabbixItem(name string, labels labels, value string, desc string) *zabbi
 	return &item
 }
 
-func discovery() {
+var (
+	// errDiscoveryMarshal is returned by discovery if the payload could not be built.
+	errDiscoveryMarshal = errors.New("could not marshal discovery data to json")
+)
+
+func discovery() error {
 	log.Debug("Running discovery")
 	discoData := make(map[string][]labels)
 	discoItemList := []labels{}
@@ -40,39 +52,51 @@ func discovery() {
 	jsonOutput, err := json.Marshal(discoData)
 	if err != nil {
 		log.Debug("Discovery failure, could not marshal to json")
-		fmt.Println("2")
-		os.Exit(2)
+		return errDiscoveryMarshal
 	}
 
 	discoveryPayload := make(map[string]interface{})
 	discoveryPayload[discoveryNameSpace+".discovery"] = string(jsonOutput)
 	log.Debug(discoveryPayload)
 	di := zabbix.MakeDataItems(discoveryPayload, zabbixFromHost)
-	sendToZabbix(di)
+	return sendToZabbix(di)
 }
 
-func updateItems() {
+func updateItems() error {
 	log.Debug("Running update-items")
 
 	// add discovery name wrap
 	newMap := make(map[string]interface{})
+	logItems := zabbix.DataItems{}
 	for _, metric := range cache.metrics {
 		key := metric.Name
+		// Log items get one value per entry, with its own time.
+		if entries, ok := metric.Value.([]logEntry); ok {
+			for _, entry := range entries {
+				logItems = append(logItems, zabbix.DataItem{
+					Hostname:  zabbixFromHost,
+					Key:       key,
+					Timestamp: entry.Time.Unix(),
+					Value:     entry.String(),
+				})
+			}
+			continue
+		}
 		newMap[key] = metric.Value
 	}
 	log.Debug("sending items : ", newMap)
 	di := zabbix.MakeDataItems(newMap, zabbixFromHost)
-	sendToZabbix(di)
+	di = append(di, logItems...)
+	return sendToZabbix(di)
 }
 
-func sendToZabbix(di zabbix.DataItems) {
+func sendToZabbix(di zabbix.DataItems) error {
 	addr, _ := net.ResolveTCPAddr("tcp", zabbixServerAddress+":"+zabbixServerPort)
 	res, err := zabbix.Send(addr, di)
 	if err != nil {
 		log.Debug("Step 4 - Sent to Zabbix Server failed : ", err)
-		fmt.Println("4")
-		os.Exit(4)
+		return err
 	}
 	log.Debug(*res)
-	fmt.Println("0")
+	return nil
 }